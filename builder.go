@@ -0,0 +1,132 @@
+package progress
+
+import "fmt"
+
+// Builder declares a Progress and its steps fluently, i.e.:
+//
+//	prog, err := progress.NewBuilder().
+//		Step("build").
+//		Step("test").DependsOn("build").Weight(2).
+//		Build()
+//
+// DependsOn and Weight apply to the last declared Step.
+// Nothing is validated until Build, which reports the first wiring mistake.
+type Builder struct {
+	opts  []Option
+	steps []*builderStep
+	err   error
+}
+
+type builderStep struct {
+	id        string
+	dependsOn []string
+	weight    float64
+}
+
+// NewBuilder creates and returns a new Builder, the 'opts' are passed to New by Build.
+func NewBuilder(opts ...Option) *Builder {
+	return &Builder{opts: opts}
+}
+
+// Step declares a new step with the provided 'id'.
+func (b *Builder) Step(id string) *Builder {
+	b.steps = append(b.steps, &builderStep{id: id})
+	return b
+}
+
+// DependsOn records that the last declared step depends on the 'ids' steps, in Step.DependsOn.
+// The 'ids' can reference steps declared later. The dependencies are only declarative, the Progress
+// does not prevent a step from starting before its dependencies are done.
+func (b *Builder) DependsOn(ids ...string) *Builder {
+	if step := b.last(); step != nil {
+		step.dependsOn = append(step.dependsOn, ids...)
+	}
+	return b
+}
+
+// Weight sets the weight of the last declared step, see Step.SetWeight.
+func (b *Builder) Weight(weight float64) *Builder {
+	if step := b.last(); step != nil {
+		step.weight = weight
+	}
+	return b
+}
+
+func (b *Builder) last() *builderStep {
+	if len(b.steps) == 0 {
+		if b.err == nil {
+			b.err = ErrBuilderRequiresStep
+		}
+		return nil
+	}
+	return b.steps[len(b.steps)-1]
+}
+
+// Build validates the declared steps and returns a new Progress with these steps, in order.
+// The step IDs must be non-empty and unique, and the dependencies must reference declared steps
+// without any cycle.
+func (b *Builder) Build() (*Progress, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	declared := make(map[string]*builderStep, len(b.steps))
+	for _, step := range b.steps {
+		if step.id == "" {
+			return nil, ErrStepRequiresID
+		}
+		if _, found := declared[step.id]; found {
+			return nil, fmt.Errorf("%w: %q", ErrStepIDShouldBeUnique, step.id)
+		}
+		declared[step.id] = step
+	}
+	for _, step := range b.steps {
+		for _, dep := range step.dependsOn {
+			if _, found := declared[dep]; !found {
+				return nil, fmt.Errorf("%w: %q depends on %q", ErrUnknownDependency, step.id, dep)
+			}
+		}
+	}
+
+	// depth-first search, a step being visited that is reached again is part of a cycle.
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(b.steps))
+	var visit func(step *builderStep) error
+	visit = func(step *builderStep) error {
+		switch marks[step.id] {
+		case visiting:
+			return fmt.Errorf("%w: %q", ErrCyclicDependency, step.id)
+		case visited:
+			return nil
+		}
+		marks[step.id] = visiting
+		for _, dep := range step.dependsOn {
+			if err := visit(declared[dep]); err != nil {
+				return err
+			}
+		}
+		marks[step.id] = visited
+		return nil
+	}
+	for _, step := range b.steps {
+		if err := visit(step); err != nil {
+			return nil, err
+		}
+	}
+
+	p := New(b.opts...)
+	for _, declaredStep := range b.steps {
+		step, err := p.SafeAddStep(declaredStep.id)
+		if err != nil {
+			return nil, err
+		}
+		step.Weight = declaredStep.weight
+		if len(declaredStep.dependsOn) > 0 {
+			step.DependsOn = append([]string{}, declaredStep.dependsOn...)
+		}
+	}
+	return p, nil
+}
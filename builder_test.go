@@ -0,0 +1,55 @@
+package progress_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestBuilder(t *testing.T) {
+	prog, err := progress.NewBuilder(progress.WithName("ci")).
+		Step("build").
+		Step("test").DependsOn("build").Weight(2).
+		Step("deploy").DependsOn("build", "test").
+		Build()
+	require.NoError(t, err)
+	require.Equal(t, "ci", prog.Name)
+	require.Equal(t, 3, prog.Len())
+
+	build, test, deploy := prog.Get("build"), prog.Get("test"), prog.Get("deploy")
+	require.Empty(t, build.DependsOn)
+	require.Equal(t, []string{"build"}, test.DependsOn)
+	require.Equal(t, []string{"build", "test"}, deploy.DependsOn)
+	require.Equal(t, 2.0, test.Weight)
+
+	test.Done()
+	require.Equal(t, 0.5, prog.Progress())
+}
+
+func TestBuilder_errors(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *progress.Builder
+		err     error
+	}{
+		{"empty-id", progress.NewBuilder().Step(""), progress.ErrStepRequiresID},
+		{"duplicate", progress.NewBuilder().Step("a").Step("a"), progress.ErrStepIDShouldBeUnique},
+		{"unknown-dependency", progress.NewBuilder().Step("a").DependsOn("b"), progress.ErrUnknownDependency},
+		{"self-dependency", progress.NewBuilder().Step("a").DependsOn("a"), progress.ErrCyclicDependency},
+		{"cycle", progress.NewBuilder().Step("a").DependsOn("c").Step("b").DependsOn("a").Step("c").DependsOn("b"), progress.ErrCyclicDependency},
+		{"no-step", progress.NewBuilder().Weight(2).Step("a"), progress.ErrBuilderRequiresStep},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := tc.builder.Build()
+			require.Nil(t, prog)
+			require.True(t, errors.Is(err, tc.err), err)
+		})
+	}
+
+	// forward references are allowed
+	_, err := progress.NewBuilder().Step("a").DependsOn("b").Step("b").Build()
+	require.NoError(t, err)
+}
@@ -0,0 +1,10 @@
+//go:build !go1.20
+
+package progress
+
+import "context"
+
+// contextCause returns the error of 'ctx', context.Cause is only available with Go 1.20+.
+func contextCause(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,25 @@
+//go:build go1.20
+
+package progress_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestBindErrGroup_cause(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2"))
+	prog.Get("step1").Start()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	prog.BindErrGroup(ctx)
+
+	cancel(errors.New("step2 failed: disk full"))
+	_, err := prog.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "step2 failed: disk full", prog.Get("step1").Reason)
+	require.Equal(t, "step2 failed: disk full", prog.Get("step2").Reason)
+}
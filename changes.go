@@ -0,0 +1,67 @@
+package progress
+
+// ChangeKind is the kind of a StepChange.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeState    ChangeKind = "state"
+	ChangeProgress ChangeKind = "progress"
+)
+
+// StepChange describes how a step changed between a capture (see Capture) and the current steps.
+// Before is nil for an added step, and After is nil for a removed one.
+type StepChange struct {
+	ID     string     `json:"id"`
+	Kind   ChangeKind `json:"kind"`
+	Before *Step      `json:"before,omitempty"`
+	After  *Step      `json:"after,omitempty"`
+}
+
+// Capture returns a copy of the current steps, in order, to be compared later with Changes.
+func (p *Progress) Capture() []Step {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	ret := make([]Step, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		ret = append(ret, detachedCopy(step))
+	}
+	return ret
+}
+
+// Changes compares the steps captured in 'prev' (see Capture) with the current ones.
+// It returns a change per added, removed and modified step; a step whose state changed is reported as
+// ChangeState, even if its progress rate changed too.
+// The changes follow the current order of the steps, the removed steps are listed last.
+func (p *Progress) Changes(prev []Step) []StepChange {
+	current := p.Capture()
+
+	previous := make(map[string]*Step, len(prev))
+	for idx := range prev {
+		previous[prev[idx].ID] = &prev[idx]
+	}
+
+	changes := []StepChange{}
+	seen := make(map[string]bool, len(current))
+	for idx := range current {
+		after := &current[idx]
+		seen[after.ID] = true
+		before, found := previous[after.ID]
+		switch {
+		case !found:
+			changes = append(changes, StepChange{ID: after.ID, Kind: ChangeAdded, After: after})
+		case before.State != after.State:
+			changes = append(changes, StepChange{ID: after.ID, Kind: ChangeState, Before: before, After: after})
+		case before.Progress != after.Progress:
+			changes = append(changes, StepChange{ID: after.ID, Kind: ChangeProgress, Before: before, After: after})
+		}
+	}
+	for idx := range prev {
+		if before := &prev[idx]; !seen[before.ID] {
+			changes = append(changes, StepChange{ID: before.ID, Kind: ChangeRemoved, Before: before})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,42 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestChanges(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3"))
+	prev := prog.Capture()
+	require.Len(t, prev, 3)
+	require.Empty(t, prog.Changes(prev))
+
+	prog.Get("step1").Start()
+	prog.Get("step3").SetDescription("no visible change")
+	prog.AddStep("step4")
+	changes := prog.Changes(prev)
+	require.Len(t, changes, 2)
+	require.Equal(t, "step1", changes[0].ID)
+	require.Equal(t, progress.ChangeState, changes[0].Kind)
+	require.Equal(t, progress.StateNotStarted, changes[0].Before.State)
+	require.Equal(t, progress.StateInProgress, changes[0].After.State)
+	require.Equal(t, progress.ChangeAdded, changes[1].Kind)
+	require.Nil(t, changes[1].Before)
+
+	prev = prog.Capture()
+	prog.Get("step1").SetProgress(0.8)
+	changes = prog.Changes(prev)
+	require.Len(t, changes, 1)
+	require.Equal(t, progress.ChangeProgress, changes[0].Kind)
+	require.Equal(t, 0.5, changes[0].Before.Progress)
+	require.Equal(t, 0.8, changes[0].After.Progress)
+
+	// removed steps
+	changes = progress.New(progress.WithSteps("step2")).Changes(prev)
+	require.Len(t, changes, 3)
+	require.Equal(t, progress.ChangeRemoved, changes[0].Kind)
+	require.Equal(t, "step1", changes[0].ID)
+	require.Nil(t, changes[0].After)
+}
@@ -0,0 +1,47 @@
+package progress
+
+import "sync"
+
+// The package-level functions below are backed by a default Progress, for the simple scripts that do not
+// want to pass a *Progress around. They are safe for concurrent use, like the methods they mirror.
+// Libraries should prefer explicit instances, so they don't share the steps of the program using them.
+
+var (
+	defaultMutex    sync.RWMutex
+	defaultProgress = New()
+)
+
+// Default returns the default Progress, used by the package-level functions.
+func Default() *Progress {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultProgress
+}
+
+// SetDefault replaces the default Progress, i.e., to isolate a test. It does not close the previous one.
+// A non-nil 'p' is required, else it will panic.
+func SetDefault(p *Progress) {
+	if p == nil {
+		panic("progress.SetDefault requires a non-nil Progress as argument.")
+	}
+
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultProgress = p
+}
+
+// AddStep is equivalent to Progress.AddStep on the default Progress.
+func AddStep(id string) *Step {
+	return Default().AddStep(id)
+}
+
+// Get is equivalent to Progress.Get on the default Progress.
+func Get(id string) *Step {
+	return Default().Get(id)
+}
+
+// GetSnapshot is equivalent to Progress.Snapshot on the default Progress; it is not named Snapshot, to not
+// collide with the Snapshot type.
+func GetSnapshot() Snapshot {
+	return Default().Snapshot()
+}
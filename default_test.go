@@ -0,0 +1,29 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestDefault(t *testing.T) {
+	previous := progress.Default()
+	require.NotNil(t, previous)
+	defer progress.SetDefault(previous)
+
+	prog := progress.New()
+	defer prog.Close()
+	progress.SetDefault(prog)
+	require.Same(t, prog, progress.Default())
+
+	step := progress.AddStep("step1").Start()
+	require.Same(t, step, progress.Get("step1"))
+	require.Same(t, step, prog.Get("step1"))
+	require.Equal(t, progress.StateInProgress, progress.GetSnapshot().State)
+	step.Done()
+	require.Equal(t, progress.StateDone, progress.GetSnapshot().State)
+	require.Nil(t, previous.Get("step1"))
+
+	require.Panics(t, func() { progress.SetDefault(nil) })
+}
@@ -0,0 +1,349 @@
+package progress
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+)
+
+// MarshalProto encodes the snapshot using the Protobuf wire format, see the Snapshot message in snapshot.proto.
+// The durations are encoded in nanoseconds and the timestamps in milliseconds since the Unix epoch.
+func (s Snapshot) MarshalProto() ([]byte, error) {
+	var b protoBuffer
+	b.string(1, string(s.State))
+	b.string(2, s.Doing)
+	b.int(3, int64(s.NotStarted))
+	b.int(4, int64(s.Pending))
+	b.int(5, int64(s.InProgress))
+	b.int(6, int64(s.Completed))
+	b.int(7, int64(s.Stopped))
+	b.int(8, int64(s.Cancelled))
+	b.int(9, int64(s.Failed))
+	b.int(10, int64(s.Warnings))
+	b.int(11, int64(s.Total))
+	b.double(12, s.Progress)
+	b.int(13, int64(s.TotalDuration))
+	b.int(14, int64(s.StepDuration))
+	b.int(15, int64(s.CompletionEstimate))
+	b.int(16, epochMillis(s.DoneAt))
+	b.int(17, epochMillis(s.StartedAt))
+	for _, name := range sortedPhases(s.Phases) {
+		var stats protoBuffer
+		stats.int(1, int64(s.Phases[name].Completed))
+		stats.int(2, int64(s.Phases[name].Total))
+		var entry protoBuffer
+		entry.string(1, name)
+		entry.bytes(2, stats)
+		b.bytes(18, entry)
+	}
+	b.double(19, s.CompletedPerSecond)
+	for _, doing := range s.DoingSteps {
+		var entry protoBuffer
+		entry.string(1, doing.ID)
+		entry.string(2, doing.Title)
+		entry.double(3, doing.Progress)
+		entry.bool(4, doing.Overdue)
+		b.bytes(20, entry)
+	}
+	b.int(21, int64(s.Overdue))
+	b.int(22, int64(s.ActiveDuration))
+	b.int(23, epochMillis(s.NextRetryAt))
+	for _, id := range s.RemainingSteps {
+		b.bytes(24, []byte(id))
+	}
+	for _, id := range s.InProgressSteps {
+		b.bytes(25, []byte(id))
+	}
+	return b, nil
+}
+
+// MarshalMsgpack encodes the snapshot using MessagePack, as a map with the same keys and the same omitted
+// empty values as the JSON encoding; so "total" and "progress" are always present.
+// The durations are encoded in nanoseconds and the timestamps in milliseconds since the Unix epoch.
+func (s Snapshot) MarshalMsgpack() ([]byte, error) {
+	var (
+		body msgpackBuffer
+		n    int
+	)
+	str := func(key, value string) {
+		if value != "" {
+			body.string(key)
+			body.string(value)
+			n++
+		}
+	}
+	num := func(key string, value int64) {
+		if value != 0 {
+			body.string(key)
+			body.int(value)
+			n++
+		}
+	}
+	flt := func(key string, value float64) {
+		if value != 0 {
+			body.string(key)
+			body.float(value)
+			n++
+		}
+	}
+	str("state", string(s.State))
+	str("doing", s.Doing)
+	if len(s.DoingSteps) > 0 {
+		body.string("doing_steps")
+		body.arrayHeader(len(s.DoingSteps))
+		for _, doing := range s.DoingSteps {
+			fields := 1
+			if doing.Title != "" {
+				fields++
+			}
+			if doing.Progress != 0 {
+				fields++
+			}
+			if doing.Overdue {
+				fields++
+			}
+			body.mapHeader(fields)
+			body.string("id")
+			body.string(doing.ID)
+			if doing.Title != "" {
+				body.string("title")
+				body.string(doing.Title)
+			}
+			if doing.Progress != 0 {
+				body.string("progress")
+				body.float(doing.Progress)
+			}
+			if doing.Overdue {
+				body.string("overdue")
+				body.bool(true)
+			}
+		}
+		n++
+	}
+	num("not_started", int64(s.NotStarted))
+	num("pending", int64(s.Pending))
+	num("in_progress", int64(s.InProgress))
+	num("completed", int64(s.Completed))
+	num("stopped", int64(s.Stopped))
+	num("cancelled", int64(s.Cancelled))
+	num("failed", int64(s.Failed))
+	num("overdue", int64(s.Overdue))
+	num("warnings", int64(s.Warnings))
+	body.string("total")
+	body.int(int64(s.Total))
+	body.string("progress")
+	body.float(s.Progress)
+	n += 2
+	num("total_duration", int64(s.TotalDuration))
+	num("step_duration", int64(s.StepDuration))
+	num("completion_estimate", int64(s.CompletionEstimate))
+	flt("completed_per_second", s.CompletedPerSecond)
+	num("active_duration", int64(s.ActiveDuration))
+	num("next_retry_at", epochMillis(s.NextRetryAt))
+	num("done_at", epochMillis(s.DoneAt))
+	num("started_at", epochMillis(s.StartedAt))
+	if len(s.Phases) > 0 {
+		body.string("phases")
+		body.mapHeader(len(s.Phases))
+		for _, name := range sortedPhases(s.Phases) {
+			stats := s.Phases[name]
+			body.string(name)
+			body.mapHeader(2)
+			body.string("completed")
+			body.int(int64(stats.Completed))
+			body.string("total")
+			body.int(int64(stats.Total))
+		}
+		n++
+	}
+	strs := func(key string, values []string) {
+		if len(values) > 0 {
+			body.string(key)
+			body.arrayHeader(len(values))
+			for _, value := range values {
+				body.string(value)
+			}
+			n++
+		}
+	}
+	strs("remaining_steps", s.RemainingSteps)
+	strs("in_progress_steps", s.InProgressSteps)
+
+	var b msgpackBuffer
+	b.mapHeader(n)
+	return append(b, body...), nil
+}
+
+func epochMillis(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func sortedPhases(phases map[string]PhaseStats) []string {
+	names := make([]string, 0, len(phases))
+	for name := range phases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// protoBuffer is a minimal Protobuf encoder, the zero values are omitted like in proto3.
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	*b = append(*b, buf[:n]...)
+}
+
+func (b *protoBuffer) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(uint64(v))
+}
+
+func (b *protoBuffer) bool(field int, v bool) {
+	if v {
+		b.int(field, 1)
+	}
+}
+
+func (b *protoBuffer) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, 1)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	*b = append(*b, buf[:]...)
+}
+
+func (b *protoBuffer) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.bytes(field, []byte(v))
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// msgpackBuffer is a minimal MessagePack encoder, using the most compact representation of each value.
+type msgpackBuffer []byte
+
+func (b *msgpackBuffer) mapHeader(n int) {
+	switch {
+	case n < 16:
+		*b = append(*b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		*b = append(*b, 0xde)
+		b.uint16(uint16(n))
+	default:
+		*b = append(*b, 0xdf)
+		b.uint32(uint32(n))
+	}
+}
+
+func (b *msgpackBuffer) arrayHeader(n int) {
+	switch {
+	case n < 16:
+		*b = append(*b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		*b = append(*b, 0xdc)
+		b.uint16(uint16(n))
+	default:
+		*b = append(*b, 0xdd)
+		b.uint32(uint32(n))
+	}
+}
+
+func (b *msgpackBuffer) string(v string) {
+	switch n := len(v); {
+	case n < 32:
+		*b = append(*b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		*b = append(*b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		*b = append(*b, 0xda)
+		b.uint16(uint16(n))
+	default:
+		*b = append(*b, 0xdb)
+		b.uint32(uint32(n))
+	}
+	*b = append(*b, v...)
+}
+
+func (b *msgpackBuffer) int(v int64) {
+	switch {
+	case v >= 0 && v < 128:
+		*b = append(*b, byte(v))
+	case v >= 0 && v <= math.MaxUint8:
+		*b = append(*b, 0xcc, byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		*b = append(*b, 0xcd)
+		b.uint16(uint16(v))
+	case v >= 0 && v <= math.MaxUint32:
+		*b = append(*b, 0xce)
+		b.uint32(uint32(v))
+	case v >= 0:
+		*b = append(*b, 0xcf)
+		b.uint64(uint64(v))
+	case v >= -32:
+		*b = append(*b, byte(v))
+	case v >= math.MinInt8:
+		*b = append(*b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		*b = append(*b, 0xd1)
+		b.uint16(uint16(v))
+	case v >= math.MinInt32:
+		*b = append(*b, 0xd2)
+		b.uint32(uint32(v))
+	default:
+		*b = append(*b, 0xd3)
+		b.uint64(uint64(v))
+	}
+}
+
+func (b *msgpackBuffer) bool(v bool) {
+	if v {
+		*b = append(*b, 0xc3)
+	} else {
+		*b = append(*b, 0xc2)
+	}
+}
+
+func (b *msgpackBuffer) float(v float64) {
+	*b = append(*b, 0xcb)
+	b.uint64(math.Float64bits(v))
+}
+
+func (b *msgpackBuffer) uint16(v uint16) {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	*b = append(*b, buf[:]...)
+}
+
+func (b *msgpackBuffer) uint32(v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	*b = append(*b, buf[:]...)
+}
+
+func (b *msgpackBuffer) uint64(v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	*b = append(*b, buf[:]...)
+}
@@ -0,0 +1,164 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestSnapshot_MarshalProto(t *testing.T) {
+	snapshot := progress.Snapshot{
+		State:     progress.StateDone,
+		Completed: 2,
+		Total:     2,
+		Progress:  1,
+		Phases:    map[string]progress.PhaseStats{"a": {Completed: 1, Total: 2}},
+	}
+	out, err := snapshot.MarshalProto()
+	require.NoError(t, err)
+	expected := []byte{
+		0x0a, 0x04, 'd', 'o', 'n', 'e', // state
+		0x30, 0x02, // completed
+		0x58, 0x02, // total
+		0x61, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, // progress
+		0x92, 0x01, 0x09, 0x0a, 0x01, 'a', 0x12, 0x04, 0x08, 0x01, 0x10, 0x02, // phases
+	}
+	require.Equal(t, expected, out)
+
+	// durations in nanoseconds, timestamps in epoch millis
+	startedAt := time.Unix(1, 0)
+	out, err = progress.Snapshot{TotalDuration: 300, StartedAt: &startedAt}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x68, 0xac, 0x02, 0x88, 0x01, 0xe8, 0x07}, out)
+
+	out, err = progress.Snapshot{CompletedPerSecond: 0.5}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x99, 0x01, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f}, out)
+
+	out, err = progress.Snapshot{DoingSteps: []progress.DoingEntry{{ID: "a", Progress: 0.5}, {ID: "b", Title: "c"}}}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0xa2, 0x01, 0x0c, 0x0a, 0x01, 'a', 0x19, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f, // doing_steps[0]
+		0xa2, 0x01, 0x06, 0x0a, 0x01, 'b', 0x12, 0x01, 'c', // doing_steps[1]
+	}, out)
+
+	out, err = progress.Snapshot{Overdue: 1, DoingSteps: []progress.DoingEntry{{ID: "a", Overdue: true}}}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0xa2, 0x01, 0x05, 0x0a, 0x01, 'a', 0x20, 0x01, // doing_steps
+		0xa8, 0x01, 0x01, // overdue
+	}, out)
+
+	out, err = progress.Snapshot{ActiveDuration: 300}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xb0, 0x01, 0xac, 0x02}, out)
+
+	out, err = progress.Snapshot{NextRetryAt: &startedAt}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xb8, 0x01, 0xe8, 0x07}, out)
+
+	out, err = progress.Snapshot{RemainingSteps: []string{"a", ""}, InProgressSteps: []string{"a"}}.MarshalProto()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xc2, 0x01, 0x01, 'a', 0xc2, 0x01, 0x00, 0xca, 0x01, 0x01, 'a'}, out)
+
+	out, err = progress.Snapshot{}.MarshalProto()
+	require.NoError(t, err)
+	require.Empty(t, out)
+}
+
+func TestSnapshot_MarshalMsgpack(t *testing.T) {
+	snapshot := progress.Snapshot{
+		State:     progress.StateDone,
+		Completed: 2,
+		Total:     2,
+		Progress:  1,
+		Phases:    map[string]progress.PhaseStats{"a": {Completed: 1, Total: 2}},
+	}
+	out, err := snapshot.MarshalMsgpack()
+	require.NoError(t, err)
+	expected := []byte{0x85}
+	expected = append(expected, 0xa5, 's', 't', 'a', 't', 'e', 0xa4, 'd', 'o', 'n', 'e')
+	expected = append(expected, 0xa9, 'c', 'o', 'm', 'p', 'l', 'e', 't', 'e', 'd', 0x02)
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x02)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0xa6, 'p', 'h', 'a', 's', 'e', 's', 0x81, 0xa1, 'a', 0x82)
+	expected = append(expected, 0xa9, 'c', 'o', 'm', 'p', 'l', 'e', 't', 'e', 'd', 0x01)
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x02)
+	require.Equal(t, expected, out)
+
+	startedAt := time.Unix(1, 0)
+	out, err = progress.Snapshot{TotalDuration: 300, StartedAt: &startedAt}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x84}
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0xae, 't', 'o', 't', 'a', 'l', '_', 'd', 'u', 'r', 'a', 't', 'i', 'o', 'n', 0xcd, 0x01, 0x2c)
+	expected = append(expected, 0xaa, 's', 't', 'a', 'r', 't', 'e', 'd', '_', 'a', 't', 0xcd, 0x03, 0xe8)
+	require.Equal(t, expected, out)
+
+	out, err = progress.Snapshot{CompletedPerSecond: 0.5}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x83}
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0xb4)
+	expected = append(expected, "completed_per_second"...)
+	expected = append(expected, 0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0)
+	require.Equal(t, expected, out)
+
+	out, err = progress.Snapshot{DoingSteps: []progress.DoingEntry{{ID: "a", Progress: 0.5}, {ID: "b", Title: "c"}}}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x83}
+	expected = append(expected, 0xab, 'd', 'o', 'i', 'n', 'g', '_', 's', 't', 'e', 'p', 's', 0x92)
+	expected = append(expected, 0x82, 0xa2, 'i', 'd', 0xa1, 'a')
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0x82, 0xa2, 'i', 'd', 0xa1, 'b', 0xa5, 't', 'i', 't', 'l', 'e', 0xa1, 'c')
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	require.Equal(t, expected, out)
+
+	out, err = progress.Snapshot{Overdue: 1, DoingSteps: []progress.DoingEntry{{ID: "a", Overdue: true}}}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x84}
+	expected = append(expected, 0xab, 'd', 'o', 'i', 'n', 'g', '_', 's', 't', 'e', 'p', 's', 0x91)
+	expected = append(expected, 0x82, 0xa2, 'i', 'd', 0xa1, 'a', 0xa7, 'o', 'v', 'e', 'r', 'd', 'u', 'e', 0xc3)
+	expected = append(expected, 0xa7, 'o', 'v', 'e', 'r', 'd', 'u', 'e', 0x01)
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	require.Equal(t, expected, out)
+
+	out, err = progress.Snapshot{ActiveDuration: 300}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x83}
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0xaf)
+	expected = append(expected, "active_duration"...)
+	expected = append(expected, 0xcd, 0x01, 0x2c)
+	require.Equal(t, expected, out)
+
+	out, err = progress.Snapshot{NextRetryAt: &startedAt}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x83}
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0xad)
+	expected = append(expected, "next_retry_at"...)
+	expected = append(expected, 0xcd, 0x03, 0xe8)
+	require.Equal(t, expected, out)
+
+	out, err = progress.Snapshot{RemainingSteps: []string{"a", "b"}, InProgressSteps: []string{"a"}}.MarshalMsgpack()
+	require.NoError(t, err)
+	expected = []byte{0x84}
+	expected = append(expected, 0xa5, 't', 'o', 't', 'a', 'l', 0x00)
+	expected = append(expected, 0xa8, 'p', 'r', 'o', 'g', 'r', 'e', 's', 's', 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	expected = append(expected, 0xaf)
+	expected = append(expected, "remaining_steps"...)
+	expected = append(expected, 0x92, 0xa1, 'a', 0xa1, 'b')
+	expected = append(expected, 0xb1)
+	expected = append(expected, "in_progress_steps"...)
+	expected = append(expected, 0x91, 0xa1, 'a')
+	require.Equal(t, expected, out)
+}
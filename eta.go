@@ -0,0 +1,57 @@
+package progress
+
+import "time"
+
+// ETAStrategy estimates the remaining time of a progress from the durations of its completed steps,
+// see WithETAStrategy. Its methods are called while holding the lock of the progress, so they must be fast
+// and must not call the progress; a strategy is stateful and should not be shared between progresses.
+type ETAStrategy interface {
+	// Observe records the duration of a step that was just completed.
+	Observe(stepDuration time.Duration)
+	// Estimate returns the remaining time for 'remainingSteps' steps, or zero if unknown.
+	Estimate(remainingSteps int) time.Duration
+}
+
+const defaultEMAAlpha = 0.3
+
+// ETAEma returns an ETAStrategy based on an exponential moving average of the step durations: each completed
+// step moves the average by 'alpha' times the difference with its duration, so the estimate smoothly follows
+// the recent steps instead of jumping with each of them.
+// A higher 'alpha' (up to 1) reacts faster; an 'alpha' outside of (0, 1] defaults to 0.3.
+func ETAEma(alpha float64) ETAStrategy {
+	if alpha <= 0 || alpha > 1 {
+		alpha = defaultEMAAlpha
+	}
+	return &emaETA{alpha: alpha}
+}
+
+type emaETA struct {
+	alpha   float64
+	average float64 // in nanoseconds
+	samples int
+}
+
+func (e *emaETA) Observe(stepDuration time.Duration) {
+	if e.samples == 0 {
+		e.average = float64(stepDuration)
+	} else {
+		e.average += e.alpha * (float64(stepDuration) - e.average)
+	}
+	e.samples++
+}
+
+func (e *emaETA) Estimate(remainingSteps int) time.Duration {
+	if e.samples == 0 || remainingSteps <= 0 {
+		return 0
+	}
+	return time.Duration(e.average * float64(remainingSteps))
+}
+
+// observeDone feeds the ETA strategy with the duration of a step that was just completed, it should be
+// called while holding the lock. The skipped steps are ignored, they were not actually run.
+func (p *Progress) observeDone(step *Step, now time.Time) {
+	if p.etaStrategy == nil || step.Skipped {
+		return
+	}
+	p.etaStrategy.Observe(step.durationAt(now))
+}
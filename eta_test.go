@@ -0,0 +1,72 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestETAEma(t *testing.T) {
+	eta := progress.ETAEma(0.2)
+	require.Equal(t, time.Duration(0), eta.Estimate(10))
+
+	eta.Observe(100 * time.Millisecond)
+	require.Equal(t, time.Second, eta.Estimate(10))
+	require.Equal(t, time.Duration(0), eta.Estimate(0))
+
+	// alternating fast and slow steps: the estimate stays close to the average instead of following each step
+	lowest, highest := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			eta.Observe(10 * time.Millisecond)
+		} else {
+			eta.Observe(190 * time.Millisecond)
+		}
+		if i < 10 {
+			continue // warm-up
+		}
+		estimate := eta.Estimate(1)
+		if estimate < lowest {
+			lowest = estimate
+		}
+		if estimate > highest {
+			highest = estimate
+		}
+	}
+	require.True(t, lowest > 70*time.Millisecond, lowest)
+	require.True(t, highest < 130*time.Millisecond, highest)
+
+	// alpha=1 follows the last step
+	eta = progress.ETAEma(1)
+	eta.Observe(10 * time.Millisecond)
+	eta.Observe(190 * time.Millisecond)
+	require.Equal(t, 190*time.Millisecond, eta.Estimate(1))
+}
+
+func TestWithETAStrategy(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").Start().Done()
+	prog.AddStep("step2")
+	require.Equal(t, time.Duration(0), prog.Snapshot().CompletionEstimate)
+
+	prog = progress.New(progress.WithETAStrategy(progress.ETAEma(0.5)))
+	for _, id := range []string{"step1", "step2", "step3", "step4"} {
+		prog.AddStep(id)
+	}
+	prog.Get("step1").Done() // skipped, not observed
+	require.Equal(t, time.Duration(0), prog.Snapshot().CompletionEstimate)
+
+	step := prog.Get("step2").Start()
+	time.Sleep(10 * time.Millisecond)
+	step.Done()
+	snapshot := prog.Snapshot()
+	duration := step.Duration()
+	require.True(t, duration >= 10*time.Millisecond)
+	require.Equal(t, 2*duration, snapshot.CompletionEstimate)
+
+	prog.Get("step3").Start().Done()
+	prog.Get("step4").Start().Done()
+	require.Equal(t, time.Duration(0), prog.Snapshot().CompletionEstimate) // done
+}
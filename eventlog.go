@@ -0,0 +1,86 @@
+package progress
+
+import "time"
+
+// Event is an entry of the event log, see WithEventLog.
+// It holds a copy of the step as it was published, or as it was when removed from the progress.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Step    Step      `json:"step"`
+	Removed bool      `json:"removed,omitempty"`
+}
+
+// EventLog returns a copy of the recorded events, from the oldest to the most recent.
+// It returns nil if the Progress was not created with WithEventLog.
+func (p *Progress) EventLog() []Event {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	if !p.eventLogEnabled {
+		return nil
+	}
+	return append([]Event{}, p.eventLog...)
+}
+
+// recordEvent appends a copy of the step to the event log, it should be called while holding the lock.
+func (p *Progress) recordEvent(step *Step, removed bool) {
+	p.eventLog = append(p.eventLog, Event{
+		Time:    p.now(),
+		Step:    detachedCopy(step),
+		Removed: removed,
+	})
+}
+
+// ReplayEventLog rebuilds a Progress from the events recorded by WithEventLog, in order: each event
+// adds or replaces its step, and the removed steps are removed again. The completion events are ignored.
+// The steps are ordered by their first event, so a Progress.Reorder is not reproduced, and the child
+// progresses are not replayed.
+// The returned Progress has no event log of its own.
+func ReplayEventLog(events []Event) *Progress {
+	p := New()
+	p.index = make(map[string]*Step)
+	for _, event := range events {
+		if event.Step.IsCompletion() || event.Step.ID == "" {
+			continue
+		}
+		id := event.Step.ID
+		existing, found := p.index[id]
+		if event.Removed {
+			if found {
+				for idx, step := range p.Steps {
+					if step == existing {
+						p.Steps = append(p.Steps[:idx:idx], p.Steps[idx+1:]...)
+						break
+					}
+				}
+				delete(p.index, id)
+			}
+			continue
+		}
+		// the child progress and the runtime fields belong to the recorded progress, they are not restored
+		restored := event.Step
+		restored.Child = nil
+		restored.parent = p
+		restored.attached = true
+		restored.lastPublish = time.Time{}
+		restored.publishTimer = nil
+		restored.progressFunc = nil
+		restored.stateHooks = nil
+		restored.span = nil
+		restored.spanCtx = nil
+		if found {
+			*existing = restored
+			continue
+		}
+		p.Steps = append(p.Steps, &restored)
+		p.index[id] = &restored
+	}
+	p.reindex()
+	seenGroups := map[string]bool{}
+	for _, step := range p.Steps {
+		if step.Group != "" && !seenGroups[step.Group] {
+			seenGroups[step.Group] = true
+			p.groups = append(p.groups, step.Group)
+		}
+	}
+	return p
+}
@@ -0,0 +1,60 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestEventLog(t *testing.T) {
+	require.Nil(t, progress.New().EventLog())
+
+	prog := progress.New(progress.WithEventLog())
+	prog.AddStep("step1")
+	prog.AddStep("step2").SetDescription("hello")
+	prog.AddStep("step3")
+	prog.Get("step1").Start()
+	prog.Get("step1").Done()
+	require.NoError(t, prog.RemoveStep("step3"))
+	prog.Get("step2").Done()
+
+	events := prog.EventLog()
+	require.Equal(t, 9, len(events)) // 3 adds, description, start, done, removal, done, completion
+	expected := []struct {
+		id      string
+		state   progress.State
+		removed bool
+	}{
+		{"step1", progress.StateNotStarted, false},
+		{"step2", progress.StateNotStarted, false},
+		{"step2", progress.StateNotStarted, false},
+		{"step3", progress.StateNotStarted, false},
+		{"step1", progress.StateInProgress, false},
+		{"step1", progress.StateDone, false},
+		{"step3", progress.StateNotStarted, true},
+		{"step2", progress.StateDone, false},
+	}
+	for idx, event := range events[:len(expected)] {
+		require.Equal(t, expected[idx].id, event.Step.ID, idx)
+		require.Equal(t, expected[idx].state, event.Step.State, idx)
+		require.Equal(t, expected[idx].removed, event.Removed, idx)
+		if idx > 0 {
+			require.False(t, event.Time.Before(events[idx-1].Time))
+		}
+	}
+	require.True(t, events[8].Step.IsCompletion())
+	require.Equal(t, "hello", events[2].Step.Description)
+	require.Empty(t, events[1].Step.Description) // a copy, not the live step
+
+	replayed := progress.ReplayEventLog(events)
+	require.Nil(t, replayed.EventLog())
+	require.Equal(t, 2, replayed.Len())
+	require.False(t, replayed.Has("step3"))
+	require.Equal(t, "hello", replayed.Get("step2").Description)
+	require.Equal(t, prog.Snapshot(), replayed.Snapshot())
+
+	// the replayed steps are regular steps
+	replayed.AddStep("step4").Start()
+	require.Equal(t, progress.StateInProgress, replayed.Snapshot().State)
+}
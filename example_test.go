@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"moul.io/progress"
-	"moul.io/u"
 )
 
 func Example() {
@@ -32,7 +31,7 @@ func Example() {
 	// mark step2 as started
 	prog.Get("step2").Start()
 
-	fmt.Println(u.PrettyJSON(prog))
+	fmt.Println(prog.PrettyJSON())
 
 	// outputs something like this:
 	// {
@@ -86,7 +85,7 @@ func Example() {
 	//}
 }
 
-func ExampleProgressSubscribe() {
+func ExampleProgress_Subscribe() {
 	prog := progress.New()
 	defer prog.Close()
 	done := make(chan bool)
@@ -98,6 +97,10 @@ func ExampleProgressSubscribe() {
 			if step == nil {
 				break
 			}
+			if step.IsCompletion() {
+				fmt.Println("complete:", step.Snapshot.State, step.Snapshot.Completed, "steps")
+				continue
+			}
 			fmt.Println(idx, step.ID, step.State)
 			idx++
 		}
@@ -115,7 +118,7 @@ func ExampleProgressSubscribe() {
 	prog.Get("step3").Done()
 	prog.Get("step4").SetAsCurrent()
 	prog.Get("step4").Done()
-	// fmt.Println(u.PrettyJSON(prog))
+	// fmt.Println(prog.PrettyJSON())
 	<-done
 
 	// Output:
@@ -131,4 +134,5 @@ func ExampleProgressSubscribe() {
 	// 9 step3 done
 	// 10 step4 in progress
 	// 11 step4 done
+	// complete: done 4 steps
 }
@@ -0,0 +1,99 @@
+package progress
+
+// Group is a named subset of the steps of a Progress, i.e., a stage of a CI pipeline.
+// The steps of a group are regular steps of the Progress, so the overall progress still aggregates
+// all of them; the group only provides its own stats, see Group.Snapshot.
+type Group struct {
+	Name string
+
+	parent *Progress
+}
+
+// AddGroup returns the group with the provided 'name', creating it if needed.
+// A non-empty 'name' is required, else it will panic.
+func (p *Progress) AddGroup(name string) *Group {
+	if name == "" {
+		panic("progress.AddGroup requires a non-empty name as argument.")
+	}
+
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	found := false
+	for _, existing := range p.groups {
+		if existing == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		p.groups = append(p.groups, name)
+	}
+	return &Group{Name: name, parent: p}
+}
+
+// Groups returns the names of the groups, in creation order.
+func (p *Progress) Groups() []string {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return append([]string{}, p.groups...)
+}
+
+// AddStep creates and returns a new Step, part of the group, see Progress.AddStep.
+// A non-empty, unique 'id' is required, else it will panic.
+func (g *Group) AddStep(id string) *Step {
+	step, err := g.SafeAddStep(id)
+	if err != nil {
+		panic(err)
+	}
+	return step
+}
+
+// SafeAddStep is equivalent to AddStep with but returns error instead of panicking.
+func (g *Group) SafeAddStep(id string) (*Step, error) {
+	return g.parent.insertStep(id, "", 0, g.Name)
+}
+
+// Steps returns the steps of the group, in order.
+func (g *Group) Steps() []*Step {
+	g.parent.mainMutex.RLock()
+	defer g.parent.mainMutex.RUnlock()
+	steps := []*Step{}
+	for _, step := range g.parent.Steps {
+		if step.Group == g.Name {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Snapshot computes the stats of the group, as if its steps were the only steps of a Progress.
+func (g *Group) Snapshot() Snapshot {
+	g.parent.mainMutex.RLock()
+	defer g.parent.mainMutex.RUnlock()
+	return g.parent.groupSnapshot(g.Name)
+}
+
+// GroupSnapshots returns the stats of each group, by name, see Group.Snapshot.
+func (p *Progress) GroupSnapshots() map[string]Snapshot {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	ret := make(map[string]Snapshot, len(p.groups))
+	for _, name := range p.groups {
+		ret[name] = p.groupSnapshot(name)
+	}
+	return ret
+}
+
+// groupSnapshot computes the snapshot of a group, it should be called while holding the lock.
+func (p *Progress) groupSnapshot(name string) Snapshot {
+	pool := &Progress{phaseLabel: p.phaseLabel, clock: p.clock, snapshotStepIDs: p.snapshotStepIDs}
+	for _, step := range p.Steps {
+		if step.Group != name {
+			continue
+		}
+		stepCopy := *step
+		stepCopy.parent = pool
+		pool.Steps = append(pool.Steps, &stepCopy)
+	}
+	return pool.snapshot()
+}
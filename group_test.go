@@ -0,0 +1,42 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestGroups(t *testing.T) {
+	prog := progress.New()
+	build := prog.AddGroup("build")
+	test := prog.AddGroup("test")
+	require.Equal(t, []string{"build", "test"}, prog.Groups())
+	require.Equal(t, "build", prog.AddGroup("build").Name) // already existing
+	require.Len(t, prog.Groups(), 2)
+
+	build.AddStep("compile").Done()
+	build.AddStep("link").Start()
+	test.AddStep("unit")
+	prog.AddStep("notify")
+	require.Equal(t, "build", prog.Get("link").Group)
+	require.Len(t, build.Steps(), 2)
+	require.Equal(t, "unit", test.Steps()[0].ID)
+
+	_, err := test.SafeAddStep("compile")
+	require.Equal(t, progress.ErrStepIDShouldBeUnique, err)
+
+	snapshots := prog.GroupSnapshots()
+	require.Len(t, snapshots, 2)
+	require.Equal(t, progress.StateInProgress, snapshots["build"].State)
+	require.Equal(t, 2, snapshots["build"].Total)
+	require.Equal(t, 0.75, snapshots["build"].Progress)
+	require.Equal(t, progress.StateNotStarted, snapshots["test"].State)
+	require.Equal(t, build.Snapshot().Progress, snapshots["build"].Progress)
+
+	// the overall progress aggregates all the steps
+	require.Equal(t, 4, prog.Snapshot().Total)
+	require.Equal(t, 0.375, prog.Progress())
+
+	require.Panics(t, func() { prog.AddGroup("") })
+}
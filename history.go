@@ -0,0 +1,41 @@
+package progress
+
+import "time"
+
+// HistoryPoint is the overall progress of a Progress at a given time, see WithHistory.
+type HistoryPoint struct {
+	Time     time.Time `json:"time"`
+	Progress float64   `json:"progress"`
+}
+
+// History returns the recorded points, from the oldest to the most recent.
+// It returns nil if the Progress was not created with WithHistory.
+func (p *Progress) History() []HistoryPoint {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	if p.history == nil {
+		return nil
+	}
+
+	ret := make([]HistoryPoint, 0, p.historyLen)
+	start := p.historyHead - p.historyLen
+	if start < 0 {
+		start += len(p.history)
+	}
+	for i := 0; i < p.historyLen; i++ {
+		ret = append(ret, p.history[(start+i)%len(p.history)])
+	}
+	return ret
+}
+
+// recordHistory appends the current progress to the history ring, it should be called while holding the lock.
+func (p *Progress) recordHistory() {
+	p.history[p.historyHead] = HistoryPoint{
+		Time:     p.now(),
+		Progress: p.progress(),
+	}
+	p.historyHead = (p.historyHead + 1) % len(p.history)
+	if p.historyLen < len(p.history) {
+		p.historyLen++
+	}
+}
@@ -0,0 +1,36 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestHistory(t *testing.T) {
+	// disabled by default
+	prog := progress.New()
+	prog.AddStep("step1").Done()
+	require.Nil(t, prog.History())
+
+	prog = progress.New(progress.WithHistory(3))
+	require.Empty(t, prog.History())
+
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	history := prog.History()
+	require.Len(t, history, 2)
+	require.Equal(t, float64(0), history[0].Progress)
+	require.Equal(t, float64(0), history[1].Progress)
+
+	prog.Get("step1").Start()
+	prog.Get("step1").Done()
+	prog.Get("step2").SetProgress(0.5)
+	history = prog.History()
+	require.Len(t, history, 3)
+	require.Equal(t, 0.25, history[0].Progress)
+	require.Equal(t, 0.5, history[1].Progress)
+	require.Equal(t, 0.75, history[2].Progress)
+	require.False(t, history[1].Time.Before(history[0].Time))
+	require.False(t, history[2].Time.Before(history[1].Time))
+}
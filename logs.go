@@ -0,0 +1,39 @@
+package progress
+
+import (
+	"fmt"
+	"time"
+)
+
+// LogEntry is a timestamped message attached to a step, see Step.Log.
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Log appends a formatted message to the step logs.
+// Only the most recent entries are kept, see WithMaxLogs.
+// It returns itself (*Step) for chaining.
+func (s *Step) Log(format string, args ...interface{}) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+
+	entry := LogEntry{
+		Time:    s.parent.now(),
+		Message: fmt.Sprintf(format, args...),
+	}
+	if max := s.parent.maxLogs; max > 0 && len(s.Logs) >= max {
+		// the previous slice may be shared with published copies, so the oldest entries are
+		// dropped in a new one instead of shifting them in place
+		logs := make([]LogEntry, 0, max)
+		logs = append(logs, s.Logs[len(s.Logs)-max+1:]...)
+		s.Logs = append(logs, entry)
+	} else {
+		s.Logs = append(s.Logs, entry)
+	}
+	s.parent.publishStep(s)
+	return s
+}
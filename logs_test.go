@@ -0,0 +1,53 @@
+package progress_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestStepLog(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+	step.Log("hello %s", "world").Log("second")
+	require.Len(t, step.Logs, 2)
+	require.Equal(t, "hello world", step.Logs[0].Message)
+	require.False(t, step.Logs[0].Time.IsZero())
+
+	out, err := json.Marshal(step)
+	require.NoError(t, err)
+	var decoded struct {
+		Logs []progress.LogEntry `json:"logs"`
+	}
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Len(t, decoded.Logs, 2)
+	require.Equal(t, "second", decoded.Logs[1].Message)
+}
+
+func TestWithMaxLogs(t *testing.T) {
+	prog := progress.New(progress.WithMaxLogs(3))
+	step := prog.AddStep("step1")
+	for i := 0; i < 10; i++ {
+		step.Log("entry %d", i)
+	}
+	require.Len(t, step.Logs, 3)
+	require.Equal(t, "entry 7", step.Logs[0].Message)
+	require.Equal(t, "entry 9", step.Logs[2].Message)
+
+	unlimited := progress.New(progress.WithMaxLogs(0)).AddStep("step1")
+	for i := 0; i < 200; i++ {
+		unlimited.Log(fmt.Sprint(i))
+	}
+	require.Len(t, unlimited.Logs, 200)
+
+	// default limit
+	step = progress.New().AddStep("step1")
+	for i := 0; i < 200; i++ {
+		step.Log(fmt.Sprint(i))
+	}
+	require.Len(t, step.Logs, 100)
+	require.Equal(t, "199", step.Logs[99].Message)
+}
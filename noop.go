@@ -0,0 +1,58 @@
+package progress
+
+// Tracker is the subset of the Progress methods used by the libraries that report their progress, so
+// they can accept either a *Progress or Noop, without checking for nil.
+type Tracker interface {
+	AddStep(id string) *Step
+	SafeAddStep(id string) (*Step, error)
+	GetOrAddStep(id string) *Step
+	Get(id string) *Step
+	Snapshot() Snapshot
+	Progress() float64
+	Close()
+}
+
+var _ Tracker = (*Progress)(nil)
+
+// Noop returns a Tracker that tracks nothing, for the callers that don't care about the progress.
+// Its steps are inert: like the removed steps (see Progress.RemoveStep), their mutating methods are no-ops,
+// except that Step.Run and Step.RunWithRetry still call their func once, and Step.AddSubStep returns
+// another inert step. Its snapshot is always empty.
+func Noop() Tracker {
+	return noopTracker{p: &Progress{noop: true}}
+}
+
+type noopTracker struct {
+	p *Progress
+}
+
+func (t noopTracker) AddStep(id string) *Step {
+	return t.p.inertStep(id)
+}
+
+func (t noopTracker) SafeAddStep(id string) (*Step, error) {
+	return t.p.inertStep(id), nil
+}
+
+func (t noopTracker) GetOrAddStep(id string) *Step {
+	return t.p.inertStep(id)
+}
+
+func (t noopTracker) Get(id string) *Step {
+	return t.p.inertStep(id)
+}
+
+func (t noopTracker) Snapshot() Snapshot {
+	return Snapshot{State: StateNotStarted}
+}
+
+func (t noopTracker) Progress() float64 {
+	return 0
+}
+
+func (t noopTracker) Close() {}
+
+// inertStep returns a step that is not part of the progress, see Noop.
+func (p *Progress) inertStep(id string) *Step {
+	return &Step{ID: id, State: StateNotStarted, parent: p}
+}
@@ -0,0 +1,46 @@
+package progress_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestNoop(t *testing.T) {
+	tracker := progress.Noop()
+	defer tracker.Close()
+
+	step := tracker.AddStep("step1").SetDescription("ignored").Start()
+	require.Equal(t, "step1", step.ID)
+	require.Equal(t, progress.StateNotStarted, step.State)
+	require.Empty(t, step.Description)
+	step.SetProgress(0.5).Done()
+	require.Equal(t, progress.StateNotStarted, step.State)
+	require.NotNil(t, tracker.Get("step2"))
+	require.NotNil(t, step.AddSubStep("sub1"))
+	require.Equal(t, progress.StateNotStarted, tracker.Snapshot().State)
+	require.Equal(t, float64(0), tracker.Progress())
+
+	// the funcs are still called
+	called := false
+	require.NoError(t, step.Run(context.Background(), func(ctx context.Context) error {
+		called = true
+		return nil
+	}))
+	require.True(t, called)
+	errBoom := errors.New("boom")
+	err := tracker.AddStep("step3").RunWithRetry(context.Background(), func(ctx context.Context) error {
+		return errBoom
+	})
+	require.True(t, errors.Is(err, errBoom))
+}
+
+func TestTracker_progress(t *testing.T) {
+	var tracker progress.Tracker = progress.New()
+	defer tracker.Close()
+	tracker.AddStep("step1").Start()
+	require.Equal(t, progress.StateInProgress, tracker.Snapshot().State)
+}
@@ -0,0 +1,43 @@
+package progress
+
+import "sync"
+
+// Observe calls 'fn' with each published step (see Subscribe), from a goroutine managed by the progress,
+// until the returned cancel func is called or the progress is complete.
+// It saves the subscribers from unsubscribing and ranging over a chan themselves; to bind it to a
+// context, call cancel on <-ctx.Done().
+// The cancel func unsubscribes the chan and stops the goroutine: 'fn' is not called for the next events,
+// an already running call is not waited for, so cancel can be called from 'fn'. It is safe to call it
+// several times.
+func (p *Progress) Observe(fn func(*Step)) (cancel func()) {
+	ch := p.Subscribe()
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case step, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case <-stop: // cancelled while this event was received
+					return
+				default:
+				}
+				if step != nil {
+					fn(step)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			p.Unsubscribe(ch)
+		})
+	}
+}
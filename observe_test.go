@@ -0,0 +1,58 @@
+package progress_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestProgress_Observe(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+
+	var (
+		mutex  sync.Mutex
+		events []*progress.Step
+	)
+	received := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(events)
+	}
+	cancel := prog.Observe(func(step *progress.Step) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, step)
+	})
+	step := prog.AddStep("step1").Start()
+	require.Eventually(t, func() bool { return received() == 2 }, time.Second, time.Millisecond)
+	require.Equal(t, progress.StateInProgress, events[1].State)
+
+	cancel()
+	cancel() // already cancelled
+	step.SetDescription("ignored")
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, 2, received())
+}
+
+func TestProgress_Observe_completion(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+
+	done := make(chan struct{})
+	events := make(chan *progress.Step, 10)
+	var cancel func()
+	cancel = prog.Observe(func(step *progress.Step) {
+		events <- step
+		if step.IsCompletion() {
+			cancel() // from the callback
+			close(done)
+		}
+	})
+	prog.AddStep("step1").Start().Done()
+	<-done
+	require.Len(t, events, 4) // created, started, done, completion
+}
@@ -0,0 +1,190 @@
+package progress
+
+import "time"
+
+// Option configures a Progress, see New.
+type Option func(p *Progress)
+
+// WithPublishInterval coalesces rapid updates of a step (i.e., Step.SetProgress in a tight loop),
+// so that subscribers receive at most one event per 'interval' per step.
+// State changes (start, done) are always published immediately, and the latest
+// coalesced value is flushed once the interval is elapsed.
+// The snapshot subscribers (see SubscribeSnapshots) receive at most one snapshot per 'interval'.
+func WithPublishInterval(interval time.Duration) Option {
+	return func(p *Progress) {
+		p.publishInterval = interval
+	}
+}
+
+// WithClock sets the func returning the current time, used for the timestamps and the durations of the
+// steps, i.e., to test them deterministically. The timers (see WithPublishInterval) still use the real time.
+func WithClock(now func() time.Time) Option {
+	return func(p *Progress) {
+		p.clock = now
+	}
+}
+
+// WithName sets the Progress name, useful to tell several progresses apart.
+func WithName(name string) Option {
+	return func(p *Progress) {
+		p.Name = name
+	}
+}
+
+// WithMetadata attaches a custom key/value pair to the Progress.
+func WithMetadata(key, value string) Option {
+	return func(p *Progress) {
+		if p.Metadata == nil {
+			p.Metadata = make(map[string]string)
+		}
+		p.Metadata[key] = value
+	}
+}
+
+// WithHistory makes the Progress record its overall progress on each publish, keeping the last 'n' points.
+// The recorded points are available with Progress.History.
+func WithHistory(n int) Option {
+	return func(p *Progress) {
+		if n > 0 {
+			p.history = make([]HistoryPoint, n)
+		}
+	}
+}
+
+// WithEventLog makes the Progress record every published step, and every removed step, in an append-only
+// log available with Progress.EventLog, i.e., to audit a run or to rebuild it with ReplayEventLog.
+// The log is never trimmed, so it grows with the number of updates.
+func WithEventLog() Option {
+	return func(p *Progress) {
+		p.eventLogEnabled = true
+	}
+}
+
+// WithETAStrategy makes Progress.Snapshot compute Snapshot.CompletionEstimate with 'strategy', which is fed
+// with the duration of each completed step, i.e., WithETAStrategy(ETAEma(0.3)).
+// The not started, pending and in-progress steps count as remaining steps.
+func WithETAStrategy(strategy ETAStrategy) Option {
+	return func(p *Progress) {
+		p.etaStrategy = strategy
+	}
+}
+
+// WithTracer makes the Progress emit a span for each step, from its start until it is done or stopped.
+// The spans of a child progress (see Step.SetChild) are created under the span of the parent step,
+// and the child progress uses the parent tracer unless it has its own.
+func WithTracer(tracer Tracer) Option {
+	return func(p *Progress) {
+		p.tracer = tracer
+	}
+}
+
+// WithPersistentSubscribers keeps the subscribers open when the progress is complete, so the same chan
+// can observe a subsequent run (i.e., new steps added after completion).
+// Instead of closing the chan, a nil step is sent as a "run complete" event.
+// Progress.Close still closes the subscribers.
+func WithPersistentSubscribers() Option {
+	return func(p *Progress) {
+		p.persistentSubscribers = true
+	}
+}
+
+// WithStartProgress sets the progress rate of the steps started with Step.Start or Step.SetAsCurrent,
+// instead of the default 0.5; i.e., 0.0 makes a started-but-unmeasured step count as not done at all.
+func WithStartProgress(progress float64) Option {
+	return func(p *Progress) {
+		p.customStartProgress = &progress
+	}
+}
+
+// WithDoneThreshold sets the progress rate from which Step.SetProgress (and AddProgress) marks a step as done,
+// i.e., 0.999 for a producer that never reports exactly 1.0. A 1.0 always marks the step as done, while the
+// values clearly above 1.0 never do. The default is 1.0 minus 1e-9, to absorb the floating-point rounding errors.
+func WithDoneThreshold(threshold float64) Option {
+	return func(p *Progress) {
+		p.customDoneThreshold = &threshold
+	}
+}
+
+// WithPercentMode sets how the progress rates are converted to percentages, by PercentString, Percent and
+// the renderers; the default is PercentTruncate.
+func WithPercentMode(mode PercentMode) Option {
+	return func(p *Progress) {
+		p.percentMode = mode
+	}
+}
+
+// WithPhaseLabel makes Progress.Snapshot compute per-phase stats in Snapshot.Phases, grouping the steps
+// by the value of their 'key' label (see Step.SetLabel).
+func WithPhaseLabel(key string) Option {
+	return func(p *Progress) {
+		p.phaseLabel = key
+	}
+}
+
+// WithSnapshotStepIDs makes Progress.Snapshot list the ids of the remaining and in-progress steps, in
+// Snapshot.RemainingSteps and Snapshot.InProgressSteps, for the consumers that need more than the counts.
+// They are computed in the same pass as the counts, but they are opt-in to save their allocations.
+func WithSnapshotStepIDs() Option {
+	return func(p *Progress) {
+		p.snapshotStepIDs = true
+	}
+}
+
+// WithPrefix sets the label rendered at the beginning of RenderBar (and so RenderLoop), i.e., a job name.
+// The default is the name of the progress, see WithName.
+func WithPrefix(prefix string) Option {
+	return func(p *Progress) {
+		p.renderPrefix = prefix
+	}
+}
+
+// WithRenderWidth truncates the lines rendered by RenderBar (and so RenderLoop) to 'width' characters,
+// i.e., the width of the terminal. A zero or negative 'width' disables the truncation, which is the default.
+func WithRenderWidth(width int) Option {
+	return func(p *Progress) {
+		p.renderWidth = width
+	}
+}
+
+// WithStateSortOrder sets the order of the states used by the presentation accessors, SortedSteps and
+// RenderTable, i.e., WithStateSortOrder([]State{StateInProgress, StateNotStarted, StateDone}) lists the running
+// steps first. The states that are not listed come last.
+func WithStateSortOrder(order []State) Option {
+	return func(p *Progress) {
+		p.stateSortOrder = append([]State{}, order...)
+	}
+}
+
+// WithStep adds a step with the provided 'id' during the construction, like Progress.AddStep.
+// A non-empty, unique 'id' is required, else it will panic.
+func WithStep(id string) Option {
+	return func(p *Progress) {
+		p.AddStep(id)
+	}
+}
+
+// WithSteps is equivalent to several WithStep, the steps are added in order.
+func WithSteps(ids ...string) Option {
+	return func(p *Progress) {
+		for _, id := range ids {
+			p.AddStep(id)
+		}
+	}
+}
+
+// WithMaxLogs sets the maximum number of entries kept per step by Step.Log, the oldest ones are dropped.
+// The default is 100; a zero or negative 'n' disables the limit.
+func WithMaxLogs(n int) Option {
+	return func(p *Progress) {
+		p.maxLogs = n
+	}
+}
+
+// WithDynamicSteps keeps the subscribers open when all the steps are done, so more steps can be added
+// dynamically. The progress is only complete once Finish is called and all the steps are done, or when
+// Close is called.
+func WithDynamicSteps() Option {
+	return func(p *Progress) {
+		p.dynamicSteps = true
+	}
+}
@@ -1,49 +1,170 @@
 package progress
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"moul.io/u"
 )
 
 // Progress is the top-level object of the 'progress' library.
 type Progress struct {
-	Steps     []*Step   `json:"steps,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Steps     []*Step           `json:"steps,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
 
-	mainMutex   sync.RWMutex
-	subscribers map[chan *Step]struct{}
+	mainMutex             sync.RWMutex
+	subscribers           []*subscription // in subscription order, so the events are always queued the same way
+	closingSubscribers    []*subscription // removed on completion, but not detached yet, see deliver
+	snapshotSubscribers   []chan Snapshot
+	snapshotTimer         *time.Timer // the pending coalesced snapshot, see publishSnapshot
+	lastSnapshotPublish   time.Time
+	deferSnapshots        bool  // set while SetSteps applies its changes, see publishSnapshot
+	droppedEvents         int64 // atomic, updated by the dispatcher
+	publishMutex          sync.Mutex
+	publishQueue          []publication
+	publishing            bool
+	dispatchWaiting       bool // the dispatcher waits for a slow subscriber, see enqueue
+	dispatchOffset        int  // the first subscriber tried by the dispatcher, rotated on each event
+	persistentSubscribers bool
+	dynamicSteps          bool
+	owner                 *Step // the step this progress is the child of, see Step.SetChild
+	customStartProgress   *float64
+	customDoneThreshold   *float64
+	phaseLabel            string
+	percentMode           PercentMode
+	stateSortOrder        []State
+	renderPrefix          string
+	renderWidth           int
+	maxLogs               int
+	groups                []string
+	publishInterval       time.Duration
+	finished              bool
+	completed             bool // the completion of the current run was published, see completeIfTerminal
+	index                 map[string]*Step
+	tracer                Tracer
+	clock                 func() time.Time
+	noop                  bool // the progress of the inert steps, see Noop
+	snapshotStepIDs       bool
+	etaStrategy           ETAStrategy
+	spanCtx               context.Context
+	history               []HistoryPoint
+	historyHead           int
+	historyLen            int
+	eventLogEnabled       bool
+	eventLog              []Event
+	version               uint64 // incremented on each change, see changed
+	snapshotMutex         sync.Mutex
+	snapshotCache         cachedSnapshot
 }
 
+// PercentMode is the way a progress rate is converted to a percentage, see WithPercentMode.
+type PercentMode int
+
+const (
+	// PercentTruncate drops the decimals, i.e., 66.6% is 66%; this is the default.
+	PercentTruncate PercentMode = iota
+	// PercentRound rounds to the nearest integer, i.e., 66.6% is 67%.
+	PercentRound
+	// PercentFloor rounds down, i.e., 66.6% is 66%, so 100% is never shown before the end.
+	// The rates are never negative, so it only differs from PercentTruncate by absorbing the floating-point
+	// errors, i.e., 0.57 is 57% instead of 56% (0.57*100 is 56.99999999999999).
+	PercentFloor
+	// PercentCeil rounds up, i.e., 66.2% is 67%, so 0% is never shown once started.
+	PercentCeil
+)
+
 type State string
 
 const (
 	StateNotStarted State = "not started"
+	StatePending    State = "pending"
 	StateInProgress State = "in progress"
 	StateDone       State = "done"
 	StateStopped    State = "stopped"
+	StateFailed     State = "failed"
 )
 
+// states is the list of the known states, used by ParseState.
+var states = []State{StateNotStarted, StatePending, StateInProgress, StateDone, StateStopped, StateFailed}
+
+// IsTerminal returns true for the states that end a step or a progress: done, stopped and failed.
+func (s State) IsTerminal() bool {
+	switch s {
+	case StateDone, StateStopped, StateFailed:
+		return true
+	}
+	return false
+}
+
+// Code returns a stable, machine-readable token for the state, i.e., "in_progress" for StateInProgress.
+// Unlike the human-readable State string, it never contains spaces.
+func (s State) Code() string {
+	return strings.ReplaceAll(string(s), " ", "_")
+}
+
+// ParseState returns the state matching 'input', which can be either the State string or its Code.
+// If 'input' is not a known state, an error wrapping ErrUnknownState is returned.
+func ParseState(input string) (State, error) {
+	for _, state := range states {
+		if input == string(state) || input == state.Code() {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownState, input)
+}
+
 const (
 	notStartedProgress   = 0.0
 	defaultStartProgress = 0.5
 	doneProgress         = 1.0
-	publishTimeout       = 1000 * time.Millisecond
-	// based on the average usage of this library, we can't have a small number like "1" or "2".
-	// by refactoring the project, we may find a solution to update the locking strategy so we can reduce this number.
+	// doneEpsilon absorbs the rounding errors of the producers, i.e., 0.9999999999 is done, see WithDoneThreshold.
+	doneEpsilon    = 1e-9
+	publishTimeout = 1000 * time.Millisecond
+	// the events are delivered outside of the lock, the buffer only absorbs the bursts so the
+	// dispatcher rarely has to wait for a subscriber.
 	defaultSubscriberChanLength = 42
+	defaultMaxLogs              = 100
+	defaultWeight               = 1.0
+	minRateDuration             = time.Millisecond
+	// percentPrecision is the precision, in fraction of percent, kept by the non-truncating percent modes
+	percentPrecision = 1e6
 )
 
 // New creates and returns a new Progress.
-func New() *Progress {
-	return &Progress{
-		CreatedAt: time.Now(),
+func New(opts ...Option) *Progress {
+	p := &Progress{
+		maxLogs: defaultMaxLogs,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.CreatedAt = p.now()
+	return p
+}
+
+// now returns the current time, from the clock set with WithClock if any.
+// It supports a nil receiver, i.e., for the steps that are not part of a progress anymore (see Step.Clone).
+func (p *Progress) now() time.Time {
+	if p == nil || p.clock == nil {
+		return time.Now()
+	}
+	return p.clock()
+}
+
+// nonNegative clamps a duration computed from timestamps that may be out of order.
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
 }
 
 // AddStep creates and returns a new Step with the provided 'id'.
@@ -58,81 +179,436 @@ func (p *Progress) AddStep(id string) *Step {
 
 // SafeAddStep is equivalent to AddStep with but returns error instead of panicking.
 func (p *Progress) SafeAddStep(id string) (*Step, error) {
+	return p.insertStep(id, "", 0, "")
+}
+
+// AddStepAfter is equivalent to SafeAddStep, but the new step is inserted right after the 'refID' step.
+// If 'refID' does not match an existing step, ErrStepNotFound is returned.
+func (p *Progress) AddStepAfter(refID, newID string) (*Step, error) {
+	return p.insertStep(newID, refID, 1, "")
+}
+
+// AddStepBefore is equivalent to SafeAddStep, but the new step is inserted right before the 'refID' step.
+// If 'refID' does not match an existing step, ErrStepNotFound is returned.
+func (p *Progress) AddStepBefore(refID, newID string) (*Step, error) {
+	return p.insertStep(newID, refID, 0, "")
+}
+
+// GetOrAddStep returns the step with the provided 'id', creating it if needed, i.e., to replay the
+// declaration of a pipeline idempotently.
+// Unlike calling Get then AddStep, the lookup and the creation are done under the same lock, so two
+// concurrent callers always get the same step.
+// A non-empty 'id' is required, else it will panic.
+func (p *Progress) GetOrAddStep(id string) *Step {
+	if id == "" {
+		panic("progress.GetOrAddStep requires a non-empty ID as argument.")
+	}
+
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	if step := p.lookup(id); step != nil {
+		return step
+	}
+	step, err := p.insert(id, "", 0, "")
+	if err != nil {
+		panic(err)
+	}
+	return step
+}
+
+// insertStep creates a new step and inserts it at the position of the 'refID' step plus 'offset'.
+// If 'refID' is empty, the step is appended.
+// If 'group' is not empty, the step is part of this group, see AddGroup.
+func (p *Progress) insertStep(id string, refID string, offset int, group string) (*Step, error) {
 	if id == "" {
 		return nil, ErrStepRequiresID
 	}
+
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	return p.insert(id, refID, offset, group)
+}
+
+// insert is the implementation of insertStep, it should be called while holding the lock.
+func (p *Progress) insert(id string, refID string, offset int, group string) (*Step, error) {
 	step := &Step{
 		ID:       id,
 		State:    StateNotStarted,
 		Progress: notStartedProgress,
+		Group:    group,
 		parent:   p,
+		attached: true,
 	}
-
-	p.mainMutex.Lock()
-	defer p.mainMutex.Unlock()
 	if p.Steps == nil {
 		p.Steps = make([]*Step, 0)
 	}
+	p.syncIndex()
+	if p.lookup(id) != nil {
+		return nil, ErrStepIDShouldBeUnique
+	}
 
-	for _, step := range p.Steps {
-		if step.ID == id {
-			return nil, ErrStepIDShouldBeUnique
+	position := len(p.Steps)
+	if refID != "" {
+		position = -1
+		for idx, existing := range p.Steps {
+			if existing.ID == refID {
+				position = idx + offset
+				break
+			}
+		}
+		if position == -1 {
+			return nil, ErrStepNotFound
 		}
 	}
 
-	p.Steps = append(p.Steps, step)
+	p.Steps = append(p.Steps, nil)
+	copy(p.Steps[position+1:], p.Steps[position:])
+	p.Steps[position] = step
+	if position == len(p.Steps)-1 {
+		step.position = position
+		p.index[id] = step
+	} else {
+		p.reindex()
+	}
 	p.publishStep(step)
 	return step, nil
 }
 
-// publishStep iterates over subscribers and try to append a step.
+// publishStep queues a copy of the step for every matching subscriber, it should be called while
+// holding the lock. The actual delivery is done by the dispatcher, outside of the lock (see dispatch).
 func (p *Progress) publishStep(step *Step) {
-	if len(p.subscribers) == 0 {
+	p.changed()
+	if step != nil {
+		step.touch()
+	}
+	if step != nil && p.publishInterval > 0 {
+		step.lastPublish = time.Now()
+		if step.publishTimer != nil {
+			// the pending coalesced event is superseded by this one
+			step.publishTimer.Stop()
+			step.publishTimer = nil
+		}
+	}
+
+	if p.history != nil {
+		p.recordHistory()
+	}
+	if p.eventLogEnabled && step != nil {
+		p.recordEvent(step, false)
+	}
+	switch {
+	case step == nil: // the completion was already published
+	case step.IsCompletion():
+		p.flushSnapshot()
+	default:
+		p.publishSnapshot()
+	}
+
+	var (
+		stateHooks []func(old, new State, s *Step)
+		oldState   State
+		// only the completion and the transitions to a terminal state are never dropped, not the later
+		// updates of a terminal step (i.e., Log after Done)
+		terminal = step == nil || step.IsCompletion()
+	)
+	if step != nil && !step.IsCompletion() && step.State != step.publishedState {
+		terminal = step.State.IsTerminal()
+		if !terminal {
+			p.completed = false // a new run, i.e., a step was added or restarted
+		}
+		stateHooks, oldState = step.stateHooks, step.publishedState
+		step.trackStateTime(step.publishedState, p.now())
+		step.publishedState = step.State
+	}
+
+	if len(p.subscribers) == 0 && p.owner == nil && len(stateHooks) == 0 {
 		return
 	}
 
 	var stepCopyPtr *Step
 	if step != nil {
-		stepCopy := *step
+		stepCopy := detachedCopy(step)
+		stepCopy.Rate = step.rate
 		stepCopyPtr = &stepCopy
 	}
 
-	for subscriber := range p.subscribers {
-		select {
-		case subscriber <- stepCopyPtr:
-		case <-time.After(publishTimeout):
-			// debug: fmt.Println("************** DROP **************")
+	pub := p.publication(stepCopyPtr, terminal)
+	pub.source = step
+	for _, hook := range stateHooks {
+		hook := hook
+		pub.hooks = append(pub.hooks, func() { hook(oldState, stepCopyPtr.State, stepCopyPtr) })
+	}
+	p.enqueue(pub)
+}
+
+// publishRemoval queues a copy of a step that was just removed from the progress, with Removed set, for
+// every matching subscriber; it should be called while holding the lock.
+// Like a transition to a terminal state, the removal is never dropped.
+func (p *Progress) publishRemoval(step *Step) {
+	if p.history != nil {
+		p.recordHistory()
+	}
+	p.publishSnapshot()
+	if len(p.subscribers) == 0 && p.owner == nil {
+		return
+	}
+	stepCopy := detachedCopy(step)
+	stepCopy.Removed = true
+	p.enqueue(p.publication(&stepCopy, true))
+}
+
+// publication returns the publication of a step copy (or nil for a completion that was already published)
+// to the matching subscribers and to the owner of the progress; it should be called while holding the lock.
+func (p *Progress) publication(stepCopy *Step, terminal bool) publication {
+	targets := make([]*subscription, 0, len(p.subscribers))
+	for _, sub := range p.subscribers {
+		if sub.filter != nil && stepCopy != nil && !stepCopy.IsCompletion() && !sub.filter(stepCopy) {
+			continue
+		}
+		targets = append(targets, sub)
+	}
+	pub := publication{step: stepCopy, targets: targets, terminal: terminal}
+	if owner := p.owner; owner != nil {
+		pub.notify = func() { owner.childChanged(p) }
+	}
+	return pub
+}
+
+// detachedCopy returns a copy of the step whose mutators are no-ops, like a removed step, so the receivers
+// of the copy (i.e., the subscribers) can't alter the progress; it should be called while holding the lock.
+func detachedCopy(step *Step) Step {
+	ret := *step
+	ret.attached = false
+	return ret
+}
+
+// publishStepCoalesced is equivalent to publishStep, but it respects the configured publish interval.
+// If the step was published too recently, the event is delayed until the end of the interval,
+// where only the latest version of the step is published.
+func (p *Progress) publishStepCoalesced(step *Step) {
+	p.changed()
+	step.touch()
+	if p.publishInterval <= 0 {
+		p.publishStep(step)
+		return
+	}
+
+	elapsed := time.Since(step.lastPublish)
+	if elapsed >= p.publishInterval {
+		p.publishStep(step)
+		return
+	}
+
+	if step.publishTimer != nil { // a flush is already scheduled
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(p.publishInterval-elapsed, func() {
+		p.mainMutex.Lock()
+		defer p.mainMutex.Unlock()
+		if step.publishTimer != timer { // superseded by another publish
+			return
+		}
+		p.publishStep(step)
+	})
+	step.publishTimer = timer
+}
+
+// touch records that the step was just updated, it should be called while holding the lock.
+func (s *Step) touch() {
+	now := s.parent.now()
+	s.UpdatedAt = &now
+}
+
+// Since returns a copy of the steps updated at or after 't', in order, and the current time,
+// to be used as 't' for the next call.
+// It allows polling the changes incrementally, without keeping a subscription.
+func (p *Progress) Since(t time.Time) ([]Step, time.Time) {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	now := p.now()
+	ret := []Step{}
+	for _, step := range p.Steps {
+		if step.UpdatedAt != nil && !step.UpdatedAt.Before(t) {
+			ret = append(ret, detachedCopy(step))
 		}
 	}
+	return ret, now
 }
 
 // Subscribe registers the provided chan as a target called each time a step is changed.
+// The received steps are copies, their mutators are no-ops.
 func (p *Progress) Subscribe() chan *Step {
+	return p.subscribe(nil)
+}
+
+// SubscribeFiltered is equivalent to Subscribe, but only the steps matching 'pred' are sent to the chan.
+// The predicate is called by the publisher while holding the lock, so it should be fast and must not
+// call any locking method.
+// A nil predicate behaves like Subscribe.
+func (p *Progress) SubscribeFiltered(pred func(*Step) bool) <-chan *Step {
+	return p.subscribe(pred)
+}
+
+// subscription holds the per-subscriber settings and stats.
+type subscription struct {
+	ch       chan *Step
+	filter   func(*Step) bool
+	dropped  int64         // atomic, updated by the dispatcher
+	detached chan struct{} // closed when the subscriber is removed, so the pending events are given up
+
+	// pending holds the terminal events that the subscriber had no room for, in order; they are sent by a
+	// dedicated goroutine (see flushPending), so a slow subscriber never blocks the dispatcher.
+	pendingMutex sync.Mutex
+	pending      []*Step
+	flushing     bool
+	closing      bool // the chan should be closed once the pending events are sent
+	stalled      bool // the subscriber timed out, see deliver
+}
+
+func (p *Progress) subscribe(filter func(*Step) bool) chan *Step {
 	p.mainMutex.Lock()
 	subscriber := make(chan *Step, defaultSubscriberChanLength)
-	if p.subscribers == nil {
-		p.subscribers = make(map[chan *Step]struct{})
-	}
-	p.subscribers[subscriber] = struct{}{}
+	p.subscribers = append(p.subscribers, &subscription{ch: subscriber, filter: filter, detached: make(chan struct{})})
 	p.mainMutex.Unlock()
 	return subscriber
 }
 
+// DroppedEvents returns the total number of events that were dropped because a subscriber was too slow
+// to receive them, including the subscribers that are now closed.
+// The terminal events (a step done, stopped or failed, and the completion) are never dropped: they are
+// delivered as soon as the subscriber has room for them, so a stateful consumer never misses an outcome.
+func (p *Progress) DroppedEvents() int {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return int(atomic.LoadInt64(&p.droppedEvents))
+}
+
+// SubscriberDroppedEvents returns the number of events dropped for a specific subscriber.
+// It returns 0 if the subscriber is unknown or already closed.
+func (p *Progress) SubscriberDroppedEvents(subscriber <-chan *Step) int {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	for _, sub := range p.subscribers {
+		if sub.ch == subscriber {
+			return int(atomic.LoadInt64(&sub.dropped))
+		}
+	}
+	return 0
+}
+
+// Unsubscribe unregisters and closes a chan previously returned by Subscribe or SubscribeFiltered.
+// It is safe to call it on an already closed subscriber.
+func (p *Progress) Unsubscribe(subscriber <-chan *Step) {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	for idx, sub := range p.subscribers {
+		if sub.ch == subscriber {
+			p.subscribers = append(p.subscribers[:idx:idx], p.subscribers[idx+1:]...)
+			close(sub.detached)
+			// closed by the dispatcher, after the events that are already queued for it
+			p.enqueue(publication{close: []*subscription{sub}})
+			return
+		}
+	}
+	for idx, sub := range p.closingSubscribers {
+		if sub.ch == subscriber {
+			p.closingSubscribers = append(p.closingSubscribers[:idx:idx], p.closingSubscribers[idx+1:]...)
+			close(sub.detached) // the close is already queued
+			return
+		}
+	}
+}
+
 // Close cleans up the allocated ressources.
+// The subscribers are always closed, even with WithPersistentSubscribers, and the terminal events still
+// waiting for a slow subscriber are given up (see DroppedEvents).
+// The child progresses (see Step.SetChild) are closed too, recursively, and they stop updating their steps.
 func (p *Progress) Close() {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	p.closeSubscribers()
+	for _, sub := range p.closingSubscribers {
+		close(sub.detached)
+	}
+	p.closingSubscribers = nil
+	for _, step := range p.Steps {
+		if step.Child != nil {
+			step.Child.closeAsChild()
+		}
+	}
+}
+
+// closeAsChild detaches the progress from its owner step and closes it, it should be called while holding
+// the lock of the owner progress.
+func (p *Progress) closeAsChild() {
+	p.setOwner(nil)
+	p.Close()
+}
+
+// Wait blocks until the progress is complete (see Subscribe) and returns its final snapshot.
+// If the context is canceled first, it returns the current snapshot and the context error.
+// The internal subscription is always released before returning.
+func (p *Progress) Wait(ctx context.Context) (Snapshot, error) {
+	ch := p.Subscribe()
+	defer p.Unsubscribe(ch)
+
+	// the progress may already be complete, in this case no event will be published anymore
+	p.mainMutex.RLock()
+	if p.isComplete() {
+		snapshot := p.snapshot()
+		p.mainMutex.RUnlock()
+		return snapshot, nil
+	}
+	p.mainMutex.RUnlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return p.Snapshot(), ctx.Err()
+		case step, ok := <-ch:
+			switch {
+			case ok && step != nil && step.IsCompletion():
+				return *step.Snapshot, nil
+			case !ok || step == nil:
+				return p.Snapshot(), nil
+			}
+		}
+	}
+}
+
+// completeSubscribers notifies the subscribers that the progress is complete.
+// A completion event carrying the final snapshot is sent first (see Step.IsCompletion), then
+// the subscribers are closed; with WithPersistentSubscribers, a nil step is sent instead.
+func (p *Progress) completeSubscribers() {
+	snapshot := p.snapshot()
+	p.publishStep(&Step{
+		State:    snapshot.State,
+		Snapshot: &snapshot,
+	})
+	if p.persistentSubscribers {
+		p.publishStep(nil)
+		return
+	}
 	p.closeSubscribers()
 }
 
 func (p *Progress) closeSubscribers() {
-	for sub := range p.subscribers {
-		close(sub)
-		delete(p.subscribers, sub)
+	p.closeSnapshotSubscribers()
+	if len(p.subscribers) == 0 {
+		return
 	}
+	// still detachable by Close or Unsubscribe, until their chan is closed, see closed
+	p.closingSubscribers = append(p.closingSubscribers, p.subscribers...)
+	p.enqueue(publication{close: p.subscribers})
+	p.subscribers = nil
 }
 
 // Get retrieves a Step by its 'id'.
 // A non-empty 'id' is required, else it will panic.
 // If 'id' does not match an existing step, nil is returned.
+// See SafeGet for a lookup that never panics.
 func (p *Progress) Get(id string) *Step {
 	if id == "" {
 		panic("progress.Get requires a non-empty ID as argument.")
@@ -141,162 +617,1058 @@ func (p *Progress) Get(id string) *Step {
 	p.mainMutex.RLock()
 	defer p.mainMutex.RUnlock()
 
+	return p.lookup(id)
+}
+
+// lookup returns the step with the provided 'id', or nil, it should be called while holding the lock.
+// The index is only trusted if it matches the exported Steps, which may have been filled or edited without
+// AddStep (i.e., unmarshaled): an indexed step should still be at its position, with the same id, else the
+// steps are scanned.
+func (p *Progress) lookup(id string) *Step {
+	if len(p.index) == len(p.Steps) {
+		step, found := p.index[id]
+		if !found {
+			return nil
+		}
+		if step.position < len(p.Steps) && p.Steps[step.position] == step && step.ID == id {
+			return step
+		}
+	}
 	for _, step := range p.Steps {
 		if step.ID == id {
 			return step
 		}
 	}
-
 	return nil
 }
 
-// Snapshot represents info and stats about a progress at a given time.
-type Snapshot struct {
-	State              State         `json:"state,omitempty"`
-	Doing              string        `json:"doing,omitempty"`
-	NotStarted         int           `json:"not_started,omitempty"`
-	InProgress         int           `json:"in_progress,omitempty"`
-	Completed          int           `json:"completed,omitempty"`
-	Total              int           `json:"total,omitempty"`
-	Progress           float64       `json:"progress,omitempty"`
-	TotalDuration      time.Duration `json:"total_duration,omitempty"`
-	StepDuration       time.Duration `json:"step_duration,omitempty"`
-	CompletionEstimate time.Duration `json:"completion_estimate,omitempty"`
-	DoneAt             *time.Time    `json:"done_at,omitempty"`
-	StartedAt          *time.Time    `json:"started_at,omitempty"`
+// syncIndex rebuilds the index if it does not match the exported Steps, see lookup; it should be called
+// while holding the write lock.
+func (p *Progress) syncIndex() {
+	if p.index != nil && len(p.index) == len(p.Steps) {
+		return
+	}
+	p.reindex()
 }
 
-// Snapshot computes and returns the current stats of the Progress.
-func (p *Progress) Snapshot() Snapshot {
-	p.mainMutex.RLock()
-	defer p.mainMutex.RUnlock()
-	if len(p.Steps) == 0 {
-		return Snapshot{
-			State: StateNotStarted,
+// reindex rebuilds the index and the positions of the steps, it should be called while holding the write
+// lock, after each change of the order of the Steps.
+func (p *Progress) reindex() {
+	p.index = make(map[string]*Step, len(p.Steps))
+	for idx, step := range p.Steps {
+		if _, found := p.index[step.ID]; !found {
+			p.index[step.ID] = step
+			step.position = idx
 		}
 	}
+}
 
-	snapshot := Snapshot{
-		Total:    len(p.Steps),
-		Progress: 0,
+// SafeGet is equivalent to Get, but it returns ErrStepRequiresID for an empty 'id' and ErrStepNotFound if
+// 'id' does not match an existing step, instead of panicking or returning nil.
+func (p *Progress) SafeGet(id string) (*Step, error) {
+	if id == "" {
+		return nil, ErrStepRequiresID
 	}
 
-	doing := []string{}
-	for _, step := range p.Steps {
-		switch step.State {
-		case StateNotStarted:
-			snapshot.NotStarted++
-		case StateInProgress:
-			snapshot.InProgress++
-			doing = append(doing, step.title())
-		case StateDone:
-			snapshot.Completed++
-		case StateStopped:
-			panic(fmt.Sprintf("step cannot be in stopped state (yet!): %s", u.JSON(step)))
-		default:
-			panic(fmt.Sprintf("step is in an unexpected state: %s", u.JSON(step)))
-		}
-
-		// compute the oldest step.StartedAt
-		if step.StartedAt != nil {
-			if snapshot.StartedAt == nil {
-				snapshot.StartedAt = step.StartedAt
-			} else if step.StartedAt.Before(*snapshot.StartedAt) {
-				snapshot.StartedAt = step.StartedAt
-			}
-		}
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
 
-		// compute the most recent step.DoneAt
-		if step.DoneAt != nil {
-			if snapshot.DoneAt == nil {
-				snapshot.DoneAt = step.DoneAt
-			} else if step.DoneAt.After(*snapshot.DoneAt) {
-				snapshot.DoneAt = step.DoneAt
-			}
-		}
+	step := p.lookup(id)
+	if step == nil {
+		return nil, ErrStepNotFound
 	}
+	return step, nil
+}
 
-	snapshot.Progress = p.Progress()
+// GetMany retrieves several steps at once, in the order of the provided 'ids'.
+// The returned slice has the same length as 'ids', with nil for the ids that do not match an existing step.
+func (p *Progress) GetMany(ids ...string) []*Step {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
 
-	// compute top-level aggregates
-	{
-		snapshot.Doing = strings.Join(doing, ", ")
-		var (
-			isDone       = snapshot.Completed > 0 && snapshot.InProgress == 0 && snapshot.NotStarted == 0
-			isInProgress = snapshot.Completed < snapshot.Total && snapshot.InProgress > 0
-			isNotStarted = snapshot.Completed == 0 && snapshot.InProgress == 0
-			isStopped    = snapshot.Completed > 0 && snapshot.InProgress == 0 && snapshot.NotStarted > 0
-		)
-		switch {
-		case isDone:
-			snapshot.State = StateDone
-			if snapshot.Completed != snapshot.Total {
-				panic(fmt.Sprintf("snapshot has a strange state: %s", u.JSON(snapshot)))
-			}
-			snapshot.Progress = 1 // avoid having 0.99999999999 by adding floats together
-			snapshot.TotalDuration = snapshot.DoneAt.Sub(*snapshot.StartedAt)
-		case isInProgress:
-			snapshot.State = StateInProgress
-			snapshot.DoneAt = nil
-			snapshot.TotalDuration = time.Since(*snapshot.StartedAt)
-		case isNotStarted:
-			snapshot.State = StateNotStarted
-			snapshot.DoneAt = nil
-		case isStopped:
-			snapshot.State = StateStopped
-			snapshot.DoneAt = nil
-			snapshot.TotalDuration = time.Since(*snapshot.StartedAt)
-		default:
-			panic(fmt.Sprintf("snapshot has a strange state: %s", u.JSON(snapshot)))
-		}
+	ret := make([]*Step, len(ids))
+	for idx, id := range ids {
+		ret[idx] = p.lookup(id)
 	}
+	return ret
+}
 
-	return snapshot
+// Has returns true if a step with the provided 'id' exists.
+func (p *Progress) Has(id string) bool {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	return p.lookup(id) != nil
 }
 
-// MarshalJSON is a custom JSON marshaler that automatically computes and append the current snapshot.
-func (p *Progress) MarshalJSON() ([]byte, error) {
-	type alias Progress
-	type enriched struct {
-		*alias
-		Snapshot Snapshot `json:"snapshot"`
-	}
-	return json.Marshal(&enriched{
-		alias:    (*alias)(p),
-		Snapshot: p.Snapshot(),
-	})
+// Len returns the number of steps, it's a race-free alternative to len(Progress.Steps).
+func (p *Progress) Len() int {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	return len(p.Steps)
 }
 
-// Progress returns the current completion rate, it's a faster alternative to Progress.Snapshot().Progress.
-// The returned value is between 0.0 and 1.0.
-func (p *Progress) Progress() float64 {
+// SortedSteps returns the steps in presentation order: sorted by state, following the order configured with
+// WithStateSortOrder, and in their usual order within a state. The steps order is left untouched.
+// Without WithStateSortOrder, the steps are returned in their usual order.
+func (p *Progress) SortedSteps() []*Step {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return p.sortedSteps()
+}
+
+// sortedSteps implements SortedSteps, it should be called while holding the lock.
+func (p *Progress) sortedSteps() []*Step {
+	steps := append([]*Step{}, p.Steps...)
+	if len(p.stateSortOrder) == 0 {
+		return steps
+	}
+	rank := func(state State) int {
+		for idx, candidate := range p.stateSortOrder {
+			if candidate == state {
+				return idx
+			}
+		}
+		return len(p.stateSortOrder) // the unlisted states come last
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return rank(steps[i].State) < rank(steps[j].State)
+	})
+	return steps
+}
+
+// Each calls 'fn' for each step, in order, while holding a read lock.
+// The iteration stops as soon as 'fn' returns false.
+// Calling a locking method (i.e., Step.Start, Step.Done, Progress.AddStep) from 'fn' will deadlock;
+// collect the step IDs first and mutate the steps after Each returns instead.
+func (p *Progress) Each(fn func(*Step) bool) {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	for _, step := range p.Steps {
+		if !fn(step) {
+			return
+		}
+	}
+}
+
+// Reorder rearranges the steps to match the provided 'ids' order.
+// Steps that are not listed keep their relative order, after the listed ones.
+// If an 'id' does not match an existing step, ErrStepNotFound is returned and nothing is changed.
+// The snapshot subscribers receive the resulting snapshot, and if Snapshot.Doing changed, the step
+// subscribers receive the in-progress steps.
+func (p *Progress) Reorder(ids ...string) error {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+
+	ordered := make([]*Step, 0, len(p.Steps))
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		step := p.lookup(id)
+		if step == nil {
+			return ErrStepNotFound
+		}
+		if listed[id] {
+			continue
+		}
+		listed[id] = true
+		ordered = append(ordered, step)
+	}
+	for _, step := range p.Steps {
+		if !listed[step.ID] {
+			ordered = append(ordered, step)
+		}
+	}
+
+	changed := false
+	for idx, step := range ordered {
+		changed = changed || step != p.Steps[idx]
+	}
+	if !changed {
+		return nil
+	}
+	doing := p.snapshot().Doing
+	p.Steps = ordered
+	p.reindex()
+	p.changed()
+	if p.snapshot().Doing != doing {
+		// the step subscribers are notified through the in-progress steps, listed in Snapshot.Doing,
+		// and the snapshot subscribers only receive the final state, like SetSteps
+		p.deferSnapshots = true
+		for _, step := range p.Steps {
+			if step.State == StateInProgress {
+				p.publishStep(step)
+			}
+		}
+		p.deferSnapshots = false
+	}
+	p.publishSnapshot()
+	return nil
+}
+
+// StartSteps starts the steps with the provided 'ids' at once, like Step.Start, under a single lock
+// acquisition; each step is published once.
+// It is all-or-nothing: if an id does not match an existing step, ErrStepNotFound is returned, and if a step
+// is already in progress or done, ErrInvalidTransition is returned, without starting any step.
+func (p *Progress) StartSteps(ids ...string) error {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	steps, err := p.batch(ids, StateInProgress, StateDone)
+	if err != nil {
+		return err
+	}
+	now := p.now()
+	for _, step := range steps {
+		step.begin(p.startProgress(), now)
+		p.publishStep(step)
+	}
+	return nil
+}
+
+// DoneSteps is equivalent to StartSteps, but it marks the steps as done, like Step.Done.
+// If a step is already done, ErrInvalidTransition is returned without marking any step as done.
+func (p *Progress) DoneSteps(ids ...string) error {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	steps, err := p.batch(ids, StateDone)
+	if err != nil {
+		return err
+	}
+	now := p.now()
+	for _, step := range steps {
+		step.done(now)
+	}
+	return nil
+}
+
+// batch returns the steps matching 'ids', once each, after checking that none of them is in one of the
+// 'invalid' states; it should be called while holding the lock.
+func (p *Progress) batch(ids []string, invalid ...State) ([]*Step, error) {
+	steps := make([]*Step, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		step := p.lookup(id)
+		if step == nil {
+			return nil, fmt.Errorf("%w: %q", ErrStepNotFound, id)
+		}
+		for _, state := range invalid {
+			if step.State == state {
+				return nil, fmt.Errorf("%w: %q is already %s", ErrInvalidTransition, id, state)
+			}
+		}
+		if !seen[id] {
+			seen[id] = true
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// RemoveStep removes the step with the provided 'id' from the progress.
+// A copy of the step with Removed set is sent to the subscribers.
+// The removed step is detached: its mutating methods become no-ops (or return ErrStepDetached), so a stale
+// pointer can't update a step that is no longer part of the progress.
+// Its child progress, if any, is closed, see Close.
+// If 'id' does not match an existing step, ErrStepNotFound is returned.
+func (p *Progress) RemoveStep(id string) error {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	p.syncIndex()
+	step := p.lookup(id)
+	if step == nil {
+		return ErrStepNotFound
+	}
+	for idx, existing := range p.Steps {
+		if existing == step {
+			p.Steps = append(p.Steps[:idx:idx], p.Steps[idx+1:]...)
+			break
+		}
+	}
+	p.detach(step)
+	p.reindex()
+	p.completeIfTerminal()
+	return nil
+}
+
+// detach unindexes a step that was removed from p.Steps and publishes its removal, it should be called
+// while holding the lock.
+func (p *Progress) detach(step *Step) {
+	delete(p.index, step.ID)
+	step.attached = false
+	if step.publishTimer != nil { // the pending coalesced event would follow the removal
+		step.publishTimer.Stop()
+		step.publishTimer = nil
+	}
+	p.changed()
+	if p.eventLogEnabled {
+		p.recordEvent(step, true)
+	}
+	p.publishRemoval(step)
+	step.endSpan(ErrStepDetached)
+	if step.Child != nil {
+		step.Child.closeAsChild()
+	}
+}
+
+// SetSteps replaces the steps of the progress by the steps with the provided 'ids', in this order, i.e.,
+// when the plan of a pipeline changes.
+// The existing steps with a matching id are kept, with their state; the other ones are removed (see
+// RemoveStep) and the missing ones are added, all at once under the lock.
+// The subscribers receive the removals and the additions, and the snapshot subscribers the resulting
+// snapshot, even if the steps were only reordered.
+// The ids must be non-empty and unique, else ErrStepRequiresID or ErrStepIDShouldBeUnique is returned and
+// the progress is left untouched.
+func (p *Progress) SetSteps(ids []string) error {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			return ErrStepRequiresID
+		}
+		if wanted[id] {
+			return ErrStepIDShouldBeUnique
+		}
+		wanted[id] = true
+	}
+
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	p.syncIndex()
+	previous := p.Steps
+	// the snapshot subscribers only receive the final state, once, not the intermediate ones
+	p.deferSnapshots = true
+	defer func() { p.deferSnapshots = false }()
+	kept := make([]*Step, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		if wanted[step.ID] {
+			kept = append(kept, step)
+		} else {
+			p.detach(step)
+		}
+	}
+	p.Steps = kept
+	p.reindex()
+	for _, id := range ids {
+		if _, found := p.index[id]; !found {
+			if _, err := p.insert(id, "", 0, ""); err != nil {
+				return err
+			}
+		}
+	}
+	steps := make([]*Step, len(ids))
+	changed := len(steps) != len(previous)
+	for idx, id := range ids {
+		steps[idx] = p.index[id]
+		changed = changed || steps[idx] != previous[idx]
+	}
+	p.Steps = steps
+	p.reindex()
+	p.deferSnapshots = false
+	if changed {
+		p.changed()
+		p.publishSnapshot()
+	}
+	p.completeIfTerminal()
+	return nil
+}
+
+// Attached returns false if the step was removed from its progress, see Progress.RemoveStep.
+func (s *Step) Attached() bool {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	return s.attached
+}
+
+// Abort stops all the in-progress steps at once, with the provided 'reason', and returns the resulting snapshot.
+// The not started steps are left untouched, see AbortAll.
+func (p *Progress) Abort(reason string) Snapshot {
+	return p.abort(reason, false, false)
+}
+
+// AbortAll is equivalent to Abort, but it also stops the not started and pending steps.
+func (p *Progress) AbortAll(reason string) Snapshot {
+	return p.abort(reason, true, false)
+}
+
+func (p *Progress) abort(reason string, includeNotStarted bool, cancelled bool) Snapshot {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	now := p.now()
+	for _, step := range p.Steps {
+		if step.State == StateInProgress || (includeNotStarted && (step.State == StateNotStarted || step.State == StatePending)) {
+			step.markStopped(reason, cancelled, now)
+		}
+	}
+	snapshot := p.snapshot()
+	p.completeIfTerminal()
+	return snapshot
+}
+
+// BindErrGroup ties the progress to 'ctx', i.e., the context of an errgroup.Group: when 'ctx' is done, all
+// the steps that are not terminal yet are cancelled (see Step.Cancel), with the cause of the cancellation as
+// reason, i.e., the error of the goroutine that failed the group (with Go 1.20+, else the context error).
+// The binding stops as soon as the progress is complete or closed.
+func (p *Progress) BindErrGroup(ctx context.Context) {
+	// only the completion events and the closing are received
+	events := p.SubscribeFiltered(func(*Step) bool { return false })
+	go func() {
+		defer p.Unsubscribe(events)
+		for {
+			select {
+			case <-ctx.Done():
+				p.abort(contextCause(ctx).Error(), true, true)
+				return
+			case event, ok := <-events:
+				if !ok || event == nil || event.IsCompletion() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// IsTerminal returns true if the progress is over, whatever the outcome: done, stopped or failed.
+// Every step should be in a terminal state, so an idle progress with done and not started steps, whose State
+// is StateStopped, is not terminal.
+func (s Snapshot) IsTerminal() bool {
+	return s.State.IsTerminal() && s.NotStarted == 0 && s.Pending == 0 && s.InProgress == 0
+}
+
+// IsRunning returns true if the progress is in progress, including when its only active steps are pending.
+func (s Snapshot) IsRunning() bool {
+	return s.State == StateInProgress
+}
+
+// DoingEntry describes an in-progress step in Snapshot.DoingSteps, the structured form of Snapshot.Doing.
+type DoingEntry struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title,omitempty"`
+	Progress float64 `json:"progress,omitempty"`
+	Overdue  bool    `json:"overdue,omitempty"`
+}
+
+// Snapshot represents info and stats about a progress at a given time.
+// In JSON, "total" and "progress" are always present, even when zero, so a client can rely on them; the other
+// fields are omitted when empty.
+type Snapshot struct {
+	State              State                 `json:"state,omitempty"`
+	Doing              string                `json:"doing,omitempty"`
+	DoingSteps         []DoingEntry          `json:"doing_steps,omitempty"`
+	NotStarted         int                   `json:"not_started,omitempty"`
+	Pending            int                   `json:"pending,omitempty"`
+	InProgress         int                   `json:"in_progress,omitempty"`
+	Completed          int                   `json:"completed,omitempty"`
+	Stopped            int                   `json:"stopped,omitempty"`
+	Cancelled          int                   `json:"cancelled,omitempty"`
+	Failed             int                   `json:"failed,omitempty"`
+	Overdue            int                   `json:"overdue,omitempty"`
+	Warnings           int                   `json:"warnings,omitempty"`
+	Total              int                   `json:"total"`
+	Progress           float64               `json:"progress"`
+	TotalDuration      time.Duration         `json:"total_duration,omitempty"`
+	StepDuration       time.Duration         `json:"step_duration,omitempty"`
+	CompletionEstimate time.Duration         `json:"completion_estimate,omitempty"`
+	CompletedPerSecond float64               `json:"completed_per_second,omitempty"`
+	ActiveDuration     time.Duration         `json:"active_duration,omitempty"`
+	NextRetryAt        *time.Time            `json:"next_retry_at,omitempty"` // the earliest one, see Step.RunWithRetry
+	DoneAt             *time.Time            `json:"done_at,omitempty"`
+	StartedAt          *time.Time            `json:"started_at,omitempty"`
+	Phases             map[string]PhaseStats `json:"phases,omitempty"`
+	// RemainingSteps and InProgressSteps are the ids of the steps that are not done (see
+	// Progress.RemainingCount) and of the steps that are in progress, in order; they are only computed with
+	// WithSnapshotStepIDs.
+	RemainingSteps  []string `json:"remaining_steps,omitempty"`
+	InProgressSteps []string `json:"in_progress_steps,omitempty"`
+}
+
+// PhaseStats represents the stats of the steps sharing the same phase label, see WithPhaseLabel.
+type PhaseStats struct {
+	Completed int `json:"completed,omitempty"`
+	Total     int `json:"total,omitempty"`
+}
+
+// Snapshot computes and returns the current stats of the Progress.
+func (p *Progress) Snapshot() Snapshot {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return p.snapshot()
+}
+
+// snapshot computes the current stats of the Progress, it should be called while holding the lock.
+// If nothing changed since the previous call, the cached snapshot is returned with its durations updated,
+// see cachedSnapshot.
+// The exported fields of the steps may be written directly (i.e., unmarshaled), without a method call, so
+// they are also compared with their cached values, see cachedSnapshot.unchanged.
+func (p *Progress) snapshot() Snapshot {
+	now := p.now()
+	p.snapshotMutex.Lock()
+	defer p.snapshotMutex.Unlock()
+	if p.snapshotCache.valid && p.snapshotCache.version == p.version && p.snapshotCache.unchanged(p) {
+		return p.snapshotCache.at(p, now)
+	}
+
+	snapshot := p.snapshotAt(now)
+	p.snapshotCache = cachedSnapshot{}
+	if p.cacheable() {
+		p.snapshotCache = cachedSnapshot{
+			valid:    true,
+			version:  p.version,
+			fields:   p.fields(),
+			snapshot: snapshot,
+			ticking:  snapshot.StartedAt != nil && snapshot.State != StateDone && snapshot.State != StateNotStarted,
+		}
+		return p.snapshotCache.at(p, now) // a copy, so the caller can't alter the cache
+	}
+	return snapshot
+}
+
+// cachedSnapshot is a snapshot computed at a given version of the progress.
+// Only its durations depend on the time, they are updated on each read.
+type cachedSnapshot struct {
+	valid    bool
+	version  uint64
+	fields   []stepFields
+	snapshot Snapshot
+	ticking  bool // TotalDuration is measured until now
+}
+
+// stepFields holds the exported fields of a step read by snapshotAt, to detect the ones written directly
+// since the snapshot was cached, see unchanged. The times are compared by pointer.
+type stepFields struct {
+	step                                     *Step
+	id, state, name, description, label      string
+	progress, weight                         float64
+	warnings                                 int
+	paused                                   time.Duration
+	focused, skipped, cancelled, pausedState bool
+	startedAt, doneAt, deadline, nextRetryAt *time.Time
+}
+
+func (p *Progress) stepFields(step *Step) stepFields {
+	ret := stepFields{
+		step:        step,
+		id:          step.ID,
+		state:       string(step.State),
+		name:        step.Name,
+		description: step.Description,
+		progress:    step.Progress,
+		weight:      step.Weight,
+		warnings:    len(step.Warnings),
+		paused:      step.PausedDuration,
+		focused:     step.Focused,
+		skipped:     step.Skipped,
+		cancelled:   step.Cancelled,
+		pausedState: step.Paused,
+		startedAt:   step.StartedAt,
+		doneAt:      step.DoneAt,
+		deadline:    step.Deadline,
+		nextRetryAt: step.NextRetryAt,
+	}
+	if p.phaseLabel != "" {
+		ret.label = step.Labels[p.phaseLabel]
+	}
+	return ret
+}
+
+// fields returns the stepFields of all the steps, it should be called while holding the lock.
+func (p *Progress) fields() []stepFields {
+	ret := make([]stepFields, len(p.Steps))
+	for idx, step := range p.Steps {
+		ret[idx] = p.stepFields(step)
+	}
+	return ret
+}
+
+// unchanged returns true if the exported fields of the steps are the same as when the snapshot was cached,
+// it should be called while holding the lock.
+func (c *cachedSnapshot) unchanged(p *Progress) bool {
+	if len(c.fields) != len(p.Steps) {
+		return false
+	}
+	for idx, step := range p.Steps {
+		if c.fields[idx] != p.stepFields(step) {
+			return false
+		}
+	}
+	return true
+}
+
+// at returns a copy of the cached snapshot, with the durations computed until 'now'.
+// It should be called while holding the lock of 'p'.
+func (c *cachedSnapshot) at(p *Progress, now time.Time) Snapshot {
+	ret := c.snapshot
+	if ret.DoingSteps != nil {
+		ret.DoingSteps = append([]DoingEntry{}, ret.DoingSteps...)
+	}
+	if ret.RemainingSteps != nil {
+		ret.RemainingSteps = append([]string{}, ret.RemainingSteps...)
+	}
+	if ret.InProgressSteps != nil {
+		ret.InProgressSteps = append([]string{}, ret.InProgressSteps...)
+	}
+	if ret.Phases != nil {
+		ret.Phases = make(map[string]PhaseStats, len(c.snapshot.Phases))
+		for name, stats := range c.snapshot.Phases {
+			ret.Phases[name] = stats
+		}
+	}
+	if !c.ticking {
+		return ret
+	}
+	ret.ActiveDuration = 0
+	for _, step := range p.Steps {
+		ret.ActiveDuration += step.durationAt(now)
+	}
+	ret.TotalDuration = nonNegative(now.Sub(*ret.StartedAt))
+	ret.CompletedPerSecond = 0
+	if ret.Completed > 0 && ret.TotalDuration >= minRateDuration {
+		ret.CompletedPerSecond = float64(ret.Completed) / ret.TotalDuration.Seconds()
+	}
+	return ret
+}
+
+// cacheable returns false if the snapshot depends on more than the steps and the time, i.e., on a child
+// progress, a progress func or a deadline; it should be called while holding the lock.
+func (p *Progress) cacheable() bool {
+	for _, step := range p.Steps {
+		if step.Child != nil || step.progressFunc != nil || (step.Deadline != nil && step.State == StateInProgress) {
+			return false
+		}
+	}
+	return true
+}
+
+// changed invalidates the cached snapshot, it should be called while holding the lock, after each change.
+func (p *Progress) changed() {
+	p.version++
+}
+
+// snapshotAt is equivalent to snapshot, but the durations are computed until 'now'.
+func (p *Progress) snapshotAt(now time.Time) Snapshot {
+	if len(p.Steps) == 0 {
+		if p.finished {
+			return Snapshot{
+				State:    StateDone,
+				Progress: doneProgress,
+			}
+		}
+		return Snapshot{
+			State: StateNotStarted,
+		}
+	}
+
+	snapshot := Snapshot{
+		Total:    len(p.Steps),
+		Progress: 0,
+	}
+
+	doing := []string{}
+	focused := ""
+	var skippedStartedAt *time.Time
+	for _, step := range p.Steps {
+		switch step.State {
+		case StateNotStarted:
+			snapshot.NotStarted++
+		case StatePending:
+			snapshot.Pending++
+			if step.NextRetryAt != nil && (snapshot.NextRetryAt == nil || step.NextRetryAt.Before(*snapshot.NextRetryAt)) {
+				snapshot.NextRetryAt = step.NextRetryAt
+			}
+		case StateInProgress:
+			snapshot.InProgress++
+			doing = append(doing, step.breadcrumb())
+			overdue := step.Deadline != nil && now.After(*step.Deadline)
+			if overdue {
+				snapshot.Overdue++
+			}
+			snapshot.DoingSteps = append(snapshot.DoingSteps, DoingEntry{
+				ID:       step.ID,
+				Title:    step.title(),
+				Progress: step.currentProgress(),
+				Overdue:  overdue,
+			})
+			if step.Focused {
+				focused = step.breadcrumb()
+			}
+		case StateDone:
+			snapshot.Completed++
+		case StateStopped:
+			snapshot.Stopped++
+			if step.Cancelled {
+				snapshot.Cancelled++
+			}
+		case StateFailed:
+			snapshot.Failed++
+		default:
+			// unknown state (i.e., unmarshaled from a newer version), considered as not started
+			snapshot.NotStarted++
+			snapshot.Warnings++
+		}
+		snapshot.Warnings += len(step.Warnings)
+		if p.snapshotStepIDs && step.State != StateDone { // like RemainingCount, including stopped and failed
+			snapshot.RemainingSteps = append(snapshot.RemainingSteps, step.ID)
+			if step.State == StateInProgress {
+				snapshot.InProgressSteps = append(snapshot.InProgressSteps, step.ID)
+			}
+		}
+		// the sum of the step durations, unlike TotalDuration it excludes the idle gaps between steps,
+		// and it exceeds TotalDuration when steps run in parallel
+		snapshot.ActiveDuration += step.durationAt(now)
+
+		// compute the per-phase stats
+		if phase, found := step.Labels[p.phaseLabel]; p.phaseLabel != "" && found {
+			if snapshot.Phases == nil {
+				snapshot.Phases = make(map[string]PhaseStats)
+			}
+			stats := snapshot.Phases[phase]
+			stats.Total++
+			if step.State == StateDone {
+				stats.Completed++
+			}
+			snapshot.Phases[phase] = stats
+		}
+
+		// compute the oldest step.StartedAt
+		// skipped steps are ignored, because their StartedAt is artificially set when marked as done
+		if step.StartedAt != nil && step.Skipped {
+			if skippedStartedAt == nil || step.StartedAt.Before(*skippedStartedAt) {
+				skippedStartedAt = step.StartedAt
+			}
+		} else if step.StartedAt != nil {
+			if snapshot.StartedAt == nil {
+				snapshot.StartedAt = step.StartedAt
+			} else if step.StartedAt.Before(*snapshot.StartedAt) {
+				snapshot.StartedAt = step.StartedAt
+			}
+		}
+
+		// compute the most recent step.DoneAt
+		if step.DoneAt != nil {
+			if snapshot.DoneAt == nil {
+				snapshot.DoneAt = step.DoneAt
+			} else if step.DoneAt.After(*snapshot.DoneAt) {
+				snapshot.DoneAt = step.DoneAt
+			}
+		}
+	}
+	if snapshot.StartedAt == nil {
+		// only skipped steps, fallback to their artificial StartedAt
+		snapshot.StartedAt = skippedStartedAt
+	}
+
+	snapshot.Progress = p.progress()
+
+	// compute top-level aggregates
+	{
+		snapshot.Doing = strings.Join(doing, ", ")
+		if focused != "" {
+			snapshot.Doing = focused
+		}
+		var (
+			isDone       = snapshot.Completed == snapshot.Total
+			isInProgress = snapshot.Completed < snapshot.Total && (snapshot.InProgress > 0 || snapshot.Pending > 0)
+			isNotStarted = snapshot.Completed == 0 && snapshot.InProgress == 0 && snapshot.Stopped == 0 && snapshot.Failed == 0
+			isFailed     = snapshot.Failed > 0 && snapshot.InProgress == 0
+			isStopped    = (snapshot.Completed > 0 || snapshot.Stopped > 0) && snapshot.InProgress == 0
+		)
+		switch {
+		case isDone:
+			snapshot.State = StateDone
+			snapshot.Progress = 1                                    // avoid having 0.99999999999 by adding floats together
+			if snapshot.DoneAt != nil && snapshot.StartedAt != nil { // can be missing in unmarshaled steps
+				snapshot.TotalDuration = snapshot.DoneAt.Sub(*snapshot.StartedAt)
+			}
+		case isInProgress:
+			snapshot.State = StateInProgress
+			snapshot.DoneAt = nil
+			if snapshot.StartedAt != nil { // steps can be pending without any started step
+				snapshot.TotalDuration = now.Sub(*snapshot.StartedAt)
+			}
+		case isNotStarted:
+			snapshot.State = StateNotStarted
+			snapshot.DoneAt = nil
+		case isFailed:
+			snapshot.State = StateFailed
+			snapshot.DoneAt = nil
+			if snapshot.StartedAt != nil { // steps can fail without being started
+				snapshot.TotalDuration = now.Sub(*snapshot.StartedAt)
+			}
+		case isStopped:
+			snapshot.State = StateStopped
+			snapshot.DoneAt = nil
+			if snapshot.StartedAt != nil { // steps can be stopped without being started
+				snapshot.TotalDuration = now.Sub(*snapshot.StartedAt)
+			}
+		default:
+			snapshot.State = StateNotStarted
+			snapshot.DoneAt = nil
+		}
+		// the most recent DoneAt can precede the oldest StartedAt with a skewed clock or edited timestamps;
+		// ActiveDuration is already a sum of non-negative step durations
+		snapshot.TotalDuration = nonNegative(snapshot.TotalDuration)
+
+		if p.etaStrategy != nil {
+			snapshot.CompletionEstimate = p.etaStrategy.Estimate(snapshot.NotStarted + snapshot.Pending + snapshot.InProgress)
+		}
+
+		// the throughput is meaningless until enough time is elapsed
+		if snapshot.Completed > 0 && snapshot.TotalDuration >= minRateDuration {
+			snapshot.CompletedPerSecond = float64(snapshot.Completed) / snapshot.TotalDuration.Seconds()
+		}
+	}
+
+	return snapshot
+}
+
+// SnapshotAt computes the snapshot the progress would have reported at the past time 't', based on the
+// StartedAt and DoneAt of the steps: a step is not started if it was started after 't', finished if it was
+// done (or stopped, or failed) before 't', and in progress otherwise.
+// The progress rate of the steps that were in progress at 't' is unknown, they count as just started.
+func (p *Progress) SnapshotAt(t time.Time) Snapshot {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	pool := &Progress{
+		phaseLabel:          p.phaseLabel,
+		customStartProgress: p.customStartProgress,
+	}
+	for _, step := range p.Steps {
+		stepCopy := *step
+		stepCopy.parent = pool
+		switch {
+		case step.StartedAt == nil || step.StartedAt.After(t):
+			stepCopy.State = StateNotStarted
+			stepCopy.StartedAt = nil
+			stepCopy.DoneAt = nil
+			stepCopy.Progress = notStartedProgress
+		case step.DoneAt != nil && !step.DoneAt.After(t):
+			// already finished, as of now
+		default:
+			stepCopy.State = StateInProgress
+			stepCopy.DoneAt = nil
+			stepCopy.Progress = p.startProgress()
+		}
+		pool.Steps = append(pool.Steps, &stepCopy)
+	}
+	return pool.snapshotAt(t)
+}
+
+// AggregateSnapshot computes a unified snapshot of several progresses, as if all their steps were
+// part of a single Progress; each progress is thus weighted by its number of steps.
+// The progresses are read again on each call, nil or empty progresses are ignored.
+func AggregateSnapshot(progs ...*Progress) Snapshot {
+	pool := &Progress{}
+	for _, prog := range progs {
+		if prog == nil {
+			continue
+		}
+		prog.mainMutex.RLock()
+		for _, step := range prog.Steps {
+			stepCopy := *step
+			stepCopy.parent = pool
+			pool.Steps = append(pool.Steps, &stepCopy)
+		}
+		prog.mainMutex.RUnlock()
+	}
+	return pool.Snapshot()
+}
+
+// MarshalJSON is a custom JSON marshaler that automatically computes and append the current snapshot.
+// The steps and the snapshot are taken at once, under the lock, so it can run alongside the changes.
+func (p *Progress) MarshalJSON() ([]byte, error) {
+	type alias Progress
+	type enriched struct {
+		*alias
+		Snapshot Snapshot `json:"snapshot"`
+	}
+	p.mainMutex.RLock()
+	ret := &enriched{
+		alias:    (*alias)(p.clone()),
+		Snapshot: p.snapshot(),
+	}
+	p.mainMutex.RUnlock()
+	return json.Marshal(ret)
+}
+
+// JSON returns the JSON representation of the progress, including its snapshot, see MarshalJSON.
+// If the progress cannot be marshaled (i.e., a NaN or infinite progress rate, set with SetProgress or
+// returned by a SetProgressFunc callback), a JSON object describing the error is returned instead.
+func (p *Progress) JSON() string {
+	out, err := json.Marshal(p)
+	if err != nil {
+		return jsonError(err)
+	}
+	return string(out)
+}
+
+// PrettyJSON is equivalent to JSON, but the output is indented.
+func (p *Progress) PrettyJSON() string {
+	out, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return jsonError(err)
+	}
+	return string(out)
+}
+
+func jsonError(err error) string {
+	msg, _ := json.Marshal(err.Error())
+	return `{"error":` + string(msg) + `}`
+}
+
+// Progress returns the current completion rate, it's a faster alternative to Progress.Snapshot().Progress.
+// The returned value is between 0.0 and 1.0.
+func (p *Progress) Progress() float64 {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return p.progress()
+}
+
+// progress computes the current completion rate, it should be called while holding the lock.
+func (p *Progress) progress() float64 {
 	total := len(p.Steps)
+	if total == 0 && p.finished {
+		return doneProgress
+	}
 	progress := notStartedProgress
+	done := 0
+	totalWeight := 0.0
+	for _, step := range p.Steps {
+		totalWeight += step.weight()
+	}
 	for _, step := range p.Steps {
+		share := step.weight() / totalWeight
 		switch step.State {
-		case StateNotStarted:
+		case StateNotStarted, StatePending:
 			// noop
 		case StateInProgress:
 			// in-progress task count as partially done
-			progress += (step.Progress / float64(total))
-			// FIXME: support per-task progress
+			progress += step.currentProgress() * share
 		case StateDone:
-			progress += (doneProgress / float64(total))
-		case StateStopped:
-			panic(fmt.Sprintf("step cannot be in stopped state (yet!): %s", u.JSON(step)))
+			progress += doneProgress * share
+			done++
+		case StateStopped, StateFailed:
+			// stopped and failed tasks count for the work done before being interrupted
+			progress += step.Progress * share
 		default:
-			panic(fmt.Sprintf("step is in an unexpected state: %s", u.JSON(step)))
+			// unknown state, considered as not started
 		}
 	}
+	switch {
+	case total > 0 && done == total:
+		return doneProgress // avoid having 0.99999999999 by adding floats together
+	case progress > doneProgress:
+		return doneProgress
+	case progress < notStartedProgress:
+		return notStartedProgress
+	}
 	return progress
 }
 
-func (p *Progress) isDone() bool {
-	if len(p.Steps) == 0 {
-		return false
+// PercentString returns the current completion rate as a percentage, i.e., "66%".
+// It is truncated by default, see WithPercentMode.
+func (p *Progress) PercentString() string {
+	return fmt.Sprintf("%d%%", p.Percent())
+}
+
+// Percent returns the current completion rate as a percentage, between 0 and 100.
+// It is truncated by default, see WithPercentMode.
+func (p *Progress) Percent() int {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return p.percent(p.progress())
+}
+
+// doneThreshold returns the progress rate from which SetProgress marks a step as done, see WithDoneThreshold.
+func (p *Progress) doneThreshold() float64 {
+	if p.customDoneThreshold != nil {
+		return *p.customDoneThreshold
 	}
+	return doneProgress - doneEpsilon
+}
+
+// startProgress returns the progress rate of a step that was just started.
+func (p *Progress) startProgress() float64 {
+	if p.customStartProgress != nil {
+		return *p.customStartProgress
+	}
+	return defaultStartProgress
+}
+
+// DoneCount returns the number of done steps, it's a faster alternative to Progress.Snapshot().Completed.
+func (p *Progress) DoneCount() int {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	count := 0
+	for _, step := range p.Steps {
+		if step.State == StateDone {
+			count++
+		}
+	}
+	return count
+}
+
+// RemainingCount returns the number of steps that are not done yet.
+func (p *Progress) RemainingCount() int {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+
+	count := 0
 	for _, step := range p.Steps {
 		if step.State != StateDone {
+			count++
+		}
+	}
+	return count
+}
+
+// Finish marks the progress as finished, so a progress without steps reports StateDone instead of StateNotStarted.
+// The state of a progress with steps is always computed from its steps, in this case Finish only
+// closes the subscribers if all the steps are done; with WithDynamicSteps, they are closed as soon as
+// the remaining steps are done.
+func (p *Progress) Finish() {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	p.finished = true
+	p.changed()
+	p.completeIfTerminal()
+}
+
+// IsComplete returns true if the progress is complete, i.e., its completion was published and, unless a new
+// run starts, no event will be published anymore (see Wait).
+// All the steps are either done, stopped or failed; with WithDynamicSteps, the progress is also finished.
+func (p *Progress) IsComplete() bool {
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	return p.isComplete()
+}
+
+// completeIfTerminal completes the subscribers if all the steps are terminal; with WithDynamicSteps, the
+// progress should also be finished (see Finish). It should be called while holding the lock.
+// The completion is only published once per run: a new run starts when a step is added or leaves its
+// terminal state, see publishStep.
+func (p *Progress) completeIfTerminal() {
+	if !p.completed && p.isComplete() {
+		p.completed = true
+		p.completeSubscribers()
+	}
+}
+
+// isComplete returns true if the progress is complete, see completeIfTerminal.
+func (p *Progress) isComplete() bool {
+	if p.dynamicSteps && !p.finished {
+		return false
+	}
+	return p.isTerminal()
+}
+
+// isTerminal returns true if all the steps are either done, stopped or failed.
+func (p *Progress) isTerminal() bool {
+	if len(p.Steps) == 0 {
+		return p.finished
+	}
+	for _, step := range p.Steps {
+		if !step.State.IsTerminal() {
 			return false
 		}
 	}
@@ -307,153 +1679,1269 @@ func (p *Progress) isDone() bool {
 // It always have an 'id' and can be customized using helpers.
 type Step struct {
 	ID          string      `json:"id,omitempty"`
+	Name        string      `json:"name,omitempty"`
 	Description string      `json:"description,omitempty"`
 	StartedAt   *time.Time  `json:"started_at,omitempty"`
 	DoneAt      *time.Time  `json:"done_at,omitempty"`
+	UpdatedAt   *time.Time  `json:"updated_at,omitempty"`
+	Deadline    *time.Time  `json:"deadline,omitempty"`
 	State       State       `json:"state,omitempty"`
 	Data        interface{} `json:"data,omitempty"`
 	Progress    float64     `json:"progress,omitempty"`
+	Child       *Progress   `json:"child,omitempty"`
+	Weight      float64     `json:"weight,omitempty"`
+	Skipped     bool        `json:"skipped,omitempty"`
+	Focused     bool        `json:"focused,omitempty"`
+	Paused      bool        `json:"paused,omitempty"`
+	// PausedDuration is the time spent paused, excluded from Duration, see Pause.
+	PausedDuration time.Duration `json:"paused_duration,omitempty"`
+	Count          int           `json:"count,omitempty"`
+	Total          int           `json:"total,omitempty"`
+	Bytes          int64         `json:"bytes,omitempty"`
+	TotalBytes     int64         `json:"total_bytes,omitempty"`
+	Rate           float64       `json:"rate,omitempty"`
+	Cancelled      bool          `json:"cancelled,omitempty"`
+	Reason         string        `json:"reason,omitempty"`
+	Warnings       []string      `json:"warnings,omitempty"`
+	Logs           []LogEntry    `json:"logs,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Attempts       int           `json:"attempts,omitempty"`
+	MaxAttempts    int           `json:"max_attempts,omitempty"`
+	// NextRetryAt is when a failed attempt of RunWithRetry will be retried, while the step is pending.
+	NextRetryAt *time.Time        `json:"next_retry_at,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	DependsOn   []string          `json:"depends_on,omitempty"`
+	// TimeInState is the cumulative time spent in the not started, pending and in progress states,
+	// updated on each transition; see TimeIn for a value including the current state.
+	TimeInState map[State]time.Duration `json:"time_in_state,omitempty"`
+	Snapshot    *Snapshot               `json:"snapshot,omitempty"`
+	// Removed is only set on the event sent to the subscribers when the step is removed from the progress,
+	// see Progress.RemoveStep and Progress.SetSteps.
+	Removed bool `json:"removed,omitempty"`
+
+	parent         *Progress
+	attached       bool // cleared by Progress.RemoveStep, the mutators of a detached step are no-ops
+	lastPublish    time.Time
+	publishTimer   *time.Timer
+	progressFunc   func() float64
+	stateHooks     []func(old, new State, s *Step)
+	publishedState State     // the state of the last published version, to detect the transitions
+	stateSince     time.Time // when publishedState was entered, see TimeInState
+	// rate is the transfer rate measured by SetProgressBytes, only set as Rate on the published copies
+	rate        float64
+	bytesSample time.Time
+	pausedAt    time.Time // the start of the current pause, see Pause
+	// retryBackoff is the delay before the first retry of RunWithRetry, doubled on each attempt
+	retryBackoff time.Duration
+	position     int // its index in the parent Steps, see Progress.lookup
+	span         Span
+	spanCtx      context.Context
+}
+
+// SetProgress sets the current step progress rate.
+// It may also update the current Step.State depending on the passed progress:
+// 1.0, give or take a tiny epsilon (see WithDoneThreshold), marks the step as done, any other value marks it
+// as in progress, except 0.0 on a step that was not started yet, which keeps it not started.
+// The value should be something between 0.0 and 1.0.
+func (s *Step) SetProgress(progress float64) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.setProgress(progress)
+	return s
+}
+
+// AddProgress increments the current step progress rate by 'delta', which can be negative.
+// The resulting rate is clamped between 0.0 and 1.0, then applied like with SetProgress.
+func (s *Step) AddProgress(delta float64) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	progress := s.Progress + delta
+	switch {
+	case progress > doneProgress:
+		progress = doneProgress
+	case progress < notStartedProgress:
+		progress = notStartedProgress
+	}
+	s.setProgress(progress)
+	return s
+}
+
+// setProgress implements SetProgress, it should be called while holding the lock.
+func (s *Step) setProgress(progress float64) {
+	if progress == doneProgress || (progress >= s.parent.doneThreshold() && progress <= doneProgress+doneEpsilon) {
+		if s.State == StateDone {
+			panic("cannot Step.Done() an already done step.")
+		}
+		s.done(s.parent.now())
+		return
+	}
+
+	s.Progress = progress
+	previousState := s.State
+	if progress == notStartedProgress && s.State != StateInProgress {
+		// an already started step stays in progress, at 0%, and a pending step stays pending
+		if s.State != StatePending {
+			s.State = StateNotStarted
+		}
+	} else if s.State != StateInProgress {
+		s.begin(progress, s.parent.now())
+	}
+	if s.State != previousState {
+		s.parent.publishStep(s)
+	} else {
+		s.parent.publishStepCoalesced(s)
+	}
+}
+
+// SetName sets a short step name, used instead of the description in Snapshot.Doing.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetName(name string) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Name = name
+	s.parent.publishStep(s)
+	return s
+}
+
+// SetDeadline sets the time the step is expected to be done by.
+// An in-progress step is reported as overdue by Snapshot once its deadline has passed; its state is not
+// changed.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetDeadline(deadline time.Time) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Deadline = &deadline
+	s.parent.publishStep(s)
+	return s
+}
+
+// SetWeight sets the weight of the step in the progress rate of its Progress, relatively to the other steps.
+// The default weight, used for a zero or negative 'weight', is 1.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetWeight(weight float64) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Weight = weight
+	s.parent.publishStep(s)
+	return s
+}
+
+// weight returns the effective weight of the step, see SetWeight.
+func (s *Step) weight() float64 {
+	if s.Weight <= 0 {
+		return defaultWeight
+	}
+	return s.Weight
+}
+
+// SetProgressFunc makes the progress rate of the step lazily evaluated: while the step is in progress,
+// Snapshot and Progress call 'fn' to get the current rate instead of using the value set by SetProgress.
+// The step state is left unchanged. A nil 'fn' restores the pushed value.
+// 'fn' is called while holding the read lock, so it must be fast, non-blocking and must not call any locking
+// method of the progress.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetProgressFunc(fn func() float64) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.progressFunc = fn
+	s.parent.publishStep(s)
+	return s
+}
+
+// SetProgressFromCounts stores the 'done' and 'total' counts (i.e., items processed) and sets the
+// step progress rate accordingly.
+// If 'done' is greater or equal than 'total', the step is marked as done.
+// If 'total' is zero, the counts are stored but the progress and the state are left unchanged.
+func (s *Step) SetProgressFromCounts(done, total int) *Step {
+	if done < 0 {
+		done = 0
+	}
+
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Count = done
+	s.Total = total
+	switch {
+	case total <= 0 || s.State == StateDone:
+		s.parent.publishStepCoalesced(s)
+	case done >= total:
+		s.setProgress(doneProgress)
+	default:
+		s.setProgress(float64(done) / float64(total))
+	}
+	return s
+}
+
+// SetProgressBytes is equivalent to SetProgressFromCounts, for a transfer of 'total' bytes, of which 'done'
+// are transferred. The published copies of the step (see Subscribe) carry the transfer Rate, in bytes per
+// second, measured between this update and the previous one; it is zero on the first update.
+// The Rate is not stored on the step itself.
+func (s *Step) SetProgressBytes(done, total int64) *Step {
+	if done < 0 {
+		done = 0
+	}
+
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	now := s.parent.now()
+	if !s.bytesSample.IsZero() {
+		if elapsed := now.Sub(s.bytesSample); elapsed > 0 {
+			s.rate = float64(done-s.Bytes) / elapsed.Seconds()
+		}
+	}
+	s.bytesSample = now
+	s.Bytes = done
+	s.TotalBytes = total
+	switch {
+	case total <= 0 || s.State == StateDone:
+		s.parent.publishStepCoalesced(s)
+	case done >= total:
+		s.setProgress(doneProgress)
+	default:
+		s.setProgress(float64(done) / float64(total))
+	}
+	return s
+}
+
+// SetDescription sets a custom step description.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetDescription(desc string) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Description = desc
+	s.parent.publishStep(s)
+	return s
+}
+
+// SetLabel sets a custom key/value label on the step, i.e., to group steps by phase (see WithPhaseLabel).
+// It returns itself (*Step) for chaining.
+func (s *Step) SetLabel(key, value string) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	labels := make(map[string]string, len(s.Labels)+1)
+	for k, v := range s.Labels { // copy-on-write, the published copies share the map
+		labels[k] = v
+	}
+	labels[key] = value
+	s.Labels = labels
+	s.parent.publishStep(s)
+	return s
+}
+
+// AddWarning records a non-fatal issue on the step, without changing its state.
+// It returns itself (*Step) for chaining.
+func (s *Step) AddWarning(msg string) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Warnings = append(s.Warnings, msg)
+	s.parent.publishStep(s)
+	return s
+}
+
+// SetData sets a custom step data.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetData(data interface{}) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Data = data
+	s.parent.publishStep(s)
+	return s
+}
+
+// SetChild attaches a nested Progress to the step.
+// While the step is in progress, its progress rate is the one of the child. The step is started on the
+// first change of the child, and marked as done when all the child steps are done.
+// If attaching the child would create a cycle (i.e., the child is the step progress or one of its
+// ancestors), it panics with ErrCyclicChild.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetChild(child *Progress) *Step {
+	if err := s.SafeSetChild(child); err != nil && err != ErrStepDetached {
+		panic(err)
+	}
+	return s
+}
+
+// SafeSetChild is equivalent to SetChild but returns error instead of panicking.
+func (s *Step) SafeSetChild(child *Progress) error {
+	childAttachMutex.Lock()
+	defer childAttachMutex.Unlock()
+	if s.createsCycle(child) {
+		return ErrCyclicChild
+	}
+
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return ErrStepDetached
+	}
+	s.setChild(child)
+	return nil
+}
+
+// SetWeightedChild is equivalent to SetChild, but it also sets the weight of the step (see SetWeight),
+// so the contribution of the child to the parent progress is the child progress rate multiplied by 'weight'.
+// It returns itself (*Step) for chaining.
+func (s *Step) SetWeightedChild(child *Progress, weight float64) *Step {
+	childAttachMutex.Lock()
+	defer childAttachMutex.Unlock()
+	if s.createsCycle(child) {
+		panic(ErrCyclicChild)
+	}
+
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.Weight = weight
+	s.setChild(child)
+	return s
+}
+
+// childAttachMutex serializes the attachments of the child progresses (see SetChild), so the cycles can be
+// checked before taking the lock of the step progress: the child tree is read-locked by the check, and
+// locking it while holding the step progress lock would deadlock with an attachment in the other direction.
+var childAttachMutex sync.Mutex
+
+// createsCycle returns true if attaching 'child' to the step would create a cycle, it should be called while
+// holding childAttachMutex, but not the lock of the step progress.
+func (s *Step) createsCycle(child *Progress) bool {
+	return child != nil && child.contains(s.parent)
+}
+
+// setChild attaches a nested Progress to the step, it should be called while holding the lock; the caller
+// should have checked that the child doesn't create a cycle, see createsCycle.
+func (s *Step) setChild(child *Progress) {
+	if s.Child != nil && s.Child != child {
+		s.Child.setOwner(nil)
+	}
+	s.Child = child
+	if child != nil {
+		child.setOwner(s)
+		if s.parent.tracer != nil {
+			child.inheritTracing(s.parent.tracer, s.spanCtx)
+		}
+	}
+	s.parent.publishStep(s)
+}
+
+func (p *Progress) setOwner(owner *Step) {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	p.owner = owner
+}
+
+// childChanged is called by the dispatcher of the child progress after each of its events, outside of any
+// lock; it updates the step accordingly (see SetChild) and publishes it.
+func (s *Step) childChanged(child *Progress) {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached || s.Child != child || s.State == StateDone {
+		return
+	}
+
+	child.mainMutex.RLock()
+	owned := child.owner == s // the events queued before a Close or a RemoveStep are ignored
+	state := child.snapshot().State
+	complete := child.isComplete()
+	child.mainMutex.RUnlock()
+	if !owned {
+		return
+	}
+
+	switch {
+	case complete && state == StateDone:
+		s.done(s.parent.now())
+	case s.State != StateInProgress && state != StateNotStarted:
+		s.begin(s.parent.startProgress(), s.parent.now())
+		s.parent.publishStep(s)
+	default:
+		s.parent.publishStepCoalesced(s)
+	}
+}
+
+// contains returns true if 'target' is the progress itself or one of its descendants, which are read-locked.
+// 'target' is never locked.
+func (p *Progress) contains(target *Progress) bool {
+	if p == target {
+		return true
+	}
+	p.mainMutex.RLock()
+	defer p.mainMutex.RUnlock()
+	for _, step := range p.Steps {
+		if step.Child != nil && step.Child.contains(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSubStep adds a new step with the provided 'id' to the step's child Progress and returns it.
+// The child Progress is created and attached automatically if needed.
+// A non-empty, unique 'id' is required, else it will panic; it also panics with ErrStepDetached if the step
+// was removed.
+func (s *Step) AddSubStep(id string) *Step {
+	s.parent.mainMutex.Lock()
+	if !s.attached {
+		s.parent.mainMutex.Unlock()
+		if s.parent.noop {
+			return s.parent.inertStep(id)
+		}
+		panic(ErrStepDetached)
+	}
+	if s.Child == nil {
+		s.setChild(New()) // a new progress can't create a cycle
+	}
+	child := s.Child
+	s.parent.mainMutex.Unlock()
+	return child.AddStep(id)
+}
 
-	parent *Progress
+// StepData returns the step data if it is of type T.
+// If the data is not set or is of another type, it returns the zero value of T and false.
+func StepData[T any](s *Step) (T, bool) {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	data, ok := s.Data.(T)
+	return data, ok
 }
 
-// SetProgress sets the current step progress rate.
-// It may also update the current Step.State depending on the passed progress.
-// The value should be something between 0.0 and 1.0.
-func (s *Step) SetProgress(progress float64) *Step {
-	if progress == doneProgress {
-		return s.Done()
-	}
+// Start marks a step as started.
+// The step progress is set to 0.5 by default, see WithStartProgress.
+// If a step was already InProgress or Done, it panics.
+func (s *Step) Start() *Step {
+	return s.StartWith(s.parent.startProgress())
+}
 
+// Pend marks a step as pending: it is accepted, but waiting to be started (i.e., queued for a worker).
+// A pending step counts as not started in Progress, but the overall state is in progress.
+// A stopped or failed step can be pending again (i.e., queued for a retry), its previous outcome is cleared.
+// If the step was already started or done, it panics.
+func (s *Step) Pend() *Step {
 	s.parent.mainMutex.Lock()
 	defer s.parent.mainMutex.Unlock()
-	s.Progress = progress
-	if progress == notStartedProgress {
-		s.State = StateNotStarted
-	} else {
-		s.State = StateInProgress
-		if s.StartedAt == nil {
-			now := time.Now()
-			s.StartedAt = &now
-		}
+	if !s.attached {
+		return s
+	}
+	if s.State == StateInProgress {
+		panic("cannot Step.Pend() an already in-progress step.")
+	}
+	if s.State == StateDone {
+		panic("cannot Step.Pend() an already done step.")
 	}
+	if s.State == StatePending {
+		return s
+	}
+	s.State = StatePending
+	s.Progress = notStartedProgress
+	s.DoneAt = nil
+	// the outcome of a stopped or failed step is cleared, like with Start
+	s.Cancelled = false
+	s.Reason = ""
+	s.Error = ""
 	s.parent.publishStep(s)
 	return s
 }
 
-// SetDescription sets a custom step description.
-// It returns itself (*Step) for chaining.
-func (s *Step) SetDescription(desc string) *Step {
-	s.Description = desc
+// StartWith is equivalent to Start, but it sets the provided initial progress rate.
+func (s *Step) StartWith(progress float64) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	if s.State == StateInProgress {
+		panic("cannot Step.Start() an already in-progress step.")
+	}
+	if s.State == StateDone {
+		panic("cannot Step.Start() an already done step.")
+	}
+	s.begin(progress, s.parent.now())
 	s.parent.publishStep(s)
 	return s
 }
 
-// SetData sets a custom step data.
+// begin starts a new attempt of the step, it should be called while holding the lock.
+// A stopped or failed step is restarted, and its previous outcome is cleared.
+func (s *Step) begin(progress float64, now time.Time) {
+	s.State = StateInProgress
+	s.StartedAt = &now
+	s.DoneAt = nil
+	s.Progress = progress
+	s.Skipped = false
+	s.Cancelled = false
+	s.Reason = ""
+	s.Error = ""
+	s.Paused = false
+	s.PausedDuration = 0
+	s.pausedAt = time.Time{}
+	s.NextRetryAt = nil
+	s.Attempts++
+	s.startSpan()
+}
+
+// SetMaxAttempts sets the maximum number of attempts used by RetryOrFail.
 // It returns itself (*Step) for chaining.
-func (s *Step) SetData(data interface{}) *Step {
-	s.Data = data
+func (s *Step) SetMaxAttempts(max int) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.MaxAttempts = max
 	s.parent.publishStep(s)
 	return s
 }
 
-// Start marks a step as started.
-// If a step was already InProgress or Done, it panics.
-func (s *Step) Start() *Step {
+// RetryOrFail restarts the step if it has attempts left (see SetMaxAttempts), else it marks it as failed.
+// If the step was already done, it panics.
+func (s *Step) RetryOrFail() *Step {
 	s.parent.mainMutex.Lock()
 	defer s.parent.mainMutex.Unlock()
-	if s.State == StateInProgress {
-		panic("cannot Step.Start() an already in-progress step.")
+	if !s.attached {
+		return s
 	}
 	if s.State == StateDone {
-		panic("cannot Step.Start() an already done step.")
+		panic("cannot Step.RetryOrFail() an already done step.")
+	}
+	switch {
+	case s.Attempts < s.MaxAttempts:
+		s.endSpan(ErrStepRetried)
+		s.begin(s.parent.startProgress(), s.parent.now())
+		s.parent.publishStep(s)
+	case s.State != StateFailed: // the failed check and the transition are done under the same lock
+		s.fail(ErrStepMaxAttemptsReached, s.parent.now())
 	}
-	s.State = StateInProgress
-	now := time.Now()
-	s.StartedAt = &now
-	s.Progress = defaultStartProgress
-	s.parent.publishStep(s)
 	return s
 }
 
-// SetAsCurrent stops all in-progress steps and start this one.
+// SetAsCurrent marks all the in-progress steps as done and starts this one.
+// See Focus to switch the current step without completing the others.
 func (s *Step) SetAsCurrent() *Step {
 	s.parent.mainMutex.Lock()
 	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
 	if s.State == StateInProgress {
 		panic("cannot Step.Start() an already in-progress step.")
 	}
 	if s.State == StateDone {
 		panic("cannot Step.Start() an already done step.")
 	}
-	now := time.Now()
+	now := s.parent.now()
 	for _, step := range s.parent.Steps {
 		if step.State == StateInProgress {
+			step.endPause(now)
 			step.State = StateDone
 			step.DoneAt = &now
+			step.endSpan(nil)
+			s.parent.observeDone(step, now)
 			s.parent.publishStep(step)
 		}
 	}
-	s.Progress = defaultStartProgress
-	s.State = StateInProgress
-	s.StartedAt = &now
+	s.begin(s.parent.startProgress(), now)
+	s.parent.publishStep(s)
+	return s
+}
+
+// Pause stops the clock of an in-progress step, i.e., while it waits for an external input: the time spent
+// paused is excluded from Duration. The step stays in progress, see Resume.
+// Pausing a step that is not in progress, or already paused, is a no-op.
+// It returns itself (*Step) for chaining.
+func (s *Step) Pause() *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached || s.State != StateInProgress || s.Paused {
+		return s
+	}
+	s.Paused = true
+	s.pausedAt = s.parent.now()
+	s.parent.publishStep(s)
+	return s
+}
+
+// Resume restarts the clock of a paused step, see Pause; it is a no-op if the step is not paused.
+// A paused step is also resumed when it is done, stopped or failed.
+// It returns itself (*Step) for chaining.
+func (s *Step) Resume() *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached || !s.Paused {
+		return s
+	}
+	s.endPause(s.parent.now())
+	s.parent.publishStep(s)
+	return s
+}
+
+// endPause adds the current pause, if any, to PausedDuration, it should be called while holding the lock.
+func (s *Step) endPause(now time.Time) {
+	if !s.Paused {
+		return
+	}
+	if !s.pausedAt.IsZero() {
+		s.PausedDuration += now.Sub(s.pausedAt)
+	}
+	s.Paused = false
+	s.pausedAt = time.Time{}
+}
+
+// Focus makes this step the current one, displayed alone in Snapshot.Doing.
+// Unlike SetAsCurrent, the other in-progress steps are left untouched, only their focus is removed.
+// The step is started if needed; if it was already done, it panics.
+func (s *Step) Focus() *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	if s.State == StateDone {
+		panic("cannot Step.Focus() an already done step.")
+	}
+	for _, step := range s.parent.Steps {
+		if step.Focused && step != s {
+			step.Focused = false
+			s.parent.publishStep(step)
+		}
+	}
+	s.Focused = true
+	if s.State != StateInProgress {
+		s.begin(s.parent.startProgress(), s.parent.now())
+	}
 	s.parent.publishStep(s)
 	return s
 }
 
 // Done marks a step as done.
+// If the step was never started, it is marked as skipped.
 // If the step was already done, it panics.
 func (s *Step) Done() *Step {
 	s.parent.mainMutex.Lock()
 	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
 	if s.State == StateDone {
 		panic("cannot Step.Done() an already done step.")
 	}
+	s.done(s.parent.now())
+	return s
+}
+
+// done marks the step as done and publishes it, it should be called while holding the lock.
+func (s *Step) done(now time.Time) {
+	s.endPause(now)
 	s.State = StateDone
-	now := time.Now()
 	if s.StartedAt == nil {
 		s.StartedAt = &now
+		s.Skipped = true
+	}
+	s.DoneAt = &now
+	s.endSpan(nil)
+	s.parent.observeDone(s, now)
+	s.parent.publishStep(s)
+	s.parent.completeIfTerminal()
+}
+
+// Stop marks a step as stopped by the system (i.e., a timeout), with an optional 'reason'.
+// A stopped step is terminal, but not successful.
+// If the step was already done or stopped, it panics.
+func (s *Step) Stop(reason string) *Step {
+	return s.stop(reason, false)
+}
+
+// Cancel is equivalent to Stop, but it also marks the step as explicitly cancelled (i.e., by the user).
+func (s *Step) Cancel(reason string) *Step {
+	return s.stop(reason, true)
+}
+
+func (s *Step) stop(reason string, cancelled bool) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	if s.State == StateDone {
+		panic("cannot Step.Stop() an already done step.")
+	}
+	if s.State == StateStopped {
+		panic("cannot Step.Stop() an already stopped step.")
+	}
+	s.markStopped(reason, cancelled, s.parent.now())
+	s.parent.completeIfTerminal()
+	return s
+}
+
+// Fail marks a step as failed, with the provided 'err'.
+// A failed step is terminal, but it can be restarted, see Start and RetryOrFail.
+// If the step was already done or failed, it panics.
+func (s *Step) Fail(err error) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	if s.State == StateDone {
+		panic("cannot Step.Fail() an already done step.")
+	}
+	if s.State == StateFailed {
+		panic("cannot Step.Fail() an already failed step.")
+	}
+	s.fail(err, s.parent.now())
+	return s
+}
+
+// fail marks the step as failed and publishes it, it should be called while holding the lock.
+func (s *Step) fail(err error, now time.Time) {
+	if err == nil {
+		err = ErrStepFailed
 	}
+	s.endPause(now)
+	s.State = StateFailed
+	s.Error = err.Error()
+	s.Cancelled = false // i.e., a stopped step that is failed afterwards
+	s.Reason = ""
 	s.DoneAt = &now
+	s.endSpan(err)
 	s.parent.publishStep(s)
-	if s.parent.isDone() {
-		s.parent.closeSubscribers()
+	s.parent.completeIfTerminal()
+}
+
+// ForceState sets the step state regardless of the usual transition rules, i.e., to import the state of an
+// external state machine; unlike Start, Done, Stop and Fail, it never panics.
+// The timestamps are set like with the regular transitions: a (pending or) not started step has none, an
+// in-progress step is (re)started now, and a done, stopped or failed step is finished now. A done step that
+// was never started is marked as skipped, and a failed step without error reports ErrStepFailed.
+// Forcing the current state is a no-op.
+// It returns itself (*Step) for chaining.
+func (s *Step) ForceState(state State) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached || s.State == state {
+		return s
+	}
+
+	now := s.parent.now()
+	switch state {
+	case StateInProgress:
+		s.begin(s.parent.startProgress(), now)
+		s.parent.publishStep(s)
+	case StateDone:
+		s.done(now)
+	case StateStopped:
+		s.markStopped("", false, now)
+		s.parent.completeIfTerminal()
+	case StateFailed:
+		s.endPause(now)
+		s.State = StateFailed
+		if s.Error == "" {
+			s.Error = ErrStepFailed.Error()
+		}
+		s.DoneAt = &now
+		s.endSpan(ErrStepFailed)
+		s.parent.publishStep(s)
+		s.parent.completeIfTerminal()
+	default: // not started, pending, or unknown
+		s.endSpan(nil)
+		s.Paused = false
+		s.PausedDuration = 0
+		s.pausedAt = time.Time{}
+		s.State = state
+		s.Progress = notStartedProgress
+		s.StartedAt = nil
+		s.DoneAt = nil
+		s.Skipped = false
+		s.Cancelled = false
+		s.Reason = ""
+		s.Error = ""
+		s.parent.publishStep(s)
+	}
+	return s
+}
+
+// OnStateChange registers 'fn' to be called after each state transition of the step (i.e., Start, Done, a
+// SetProgress that starts the step, or ForceState), with the previous state, the new one and a copy of the step.
+// The callbacks are called in registration order by the dispatcher, outside of the lock, after the
+// subscribers received the same event; so they can call the methods of the progress.
+// It returns itself (*Step) for chaining.
+func (s *Step) OnStateChange(fn func(old, new State, s *Step)) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	hooks := make([]func(old, new State, s *Step), 0, len(s.stateHooks)+1)
+	s.stateHooks = append(append(hooks, s.stateHooks...), fn) // copy-on-write, the queued events share the slice
+	return s
+}
+
+// Run starts the step, calls 'fn', then marks the step as done if 'fn' returns nil, or as failed otherwise.
+// A panic in 'fn' is recovered and reported as a failure, wrapping ErrStepPanicked.
+// If the step was already marked as done, stopped or failed (i.e., by 'fn' or by a cancellation), it is left
+// untouched.
+// It returns the error of 'fn', or ErrStepDetached without calling 'fn' if the step was removed.
+// For a step of Noop, 'fn' is just called.
+func (s *Step) Run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if !s.Attached() {
+		if s.parent.noop {
+			return s.call(ctx, fn)
+		}
+		return ErrStepDetached
 	}
+	s.Start()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrStepPanicked, r)
+		}
+		s.parent.mainMutex.Lock()
+		defer s.parent.mainMutex.Unlock()
+		switch {
+		case !s.attached || s.State.IsTerminal():
+			// noop, i.e., stopped by Progress.BindErrGroup
+		case err != nil:
+			s.fail(err, s.parent.now())
+		default:
+			s.done(s.parent.now())
+		}
+	}()
+	return fn(ctx)
+}
+
+// WithRetryBackoff configures the automatic retries of RunWithRetry: up to 'max' attempts in total, waiting
+// 'base * 2^n' after the n-th failed attempt, starting at 0.
+// It returns itself (*Step) for chaining.
+func (s *Step) WithRetryBackoff(max int, base time.Duration) *Step {
+	s.parent.mainMutex.Lock()
+	defer s.parent.mainMutex.Unlock()
+	if !s.attached {
+		return s
+	}
+	s.MaxAttempts = max
+	s.retryBackoff = base
+	s.parent.publishStep(s)
 	return s
 }
 
-// MarshalJSON is a custom JSON marshaler that automatically computes and append some runtime metadata.
+// RunWithRetry is equivalent to Run, but when 'fn' fails and the step has attempts left (see
+// WithRetryBackoff), the step is pending until its NextRetryAt, then 'fn' is called again.
+// Once the attempts are exhausted, the step is marked as failed with the last error, which is returned.
+// If the context is done while waiting, the step is cancelled and the context error is returned.
+func (s *Step) RunWithRetry(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !s.Attached() {
+		if s.parent.noop {
+			return s.call(ctx, fn)
+		}
+		return ErrStepDetached
+	}
+	s.Start()
+	for {
+		err := s.call(ctx, fn)
+
+		s.parent.mainMutex.Lock()
+		switch {
+		case !s.attached || s.State.IsTerminal(): // altered by 'fn', or stopped by Progress.BindErrGroup
+			s.parent.mainMutex.Unlock()
+			return err
+		case err == nil:
+			s.done(s.parent.now())
+			s.parent.mainMutex.Unlock()
+			return nil
+		case s.Attempts >= s.MaxAttempts:
+			s.fail(err, s.parent.now())
+			s.parent.mainMutex.Unlock()
+			return err
+		}
+		delay := s.retryBackoff << uint(s.Attempts-1)
+		next := s.parent.now().Add(delay)
+		s.State = StatePending
+		s.Error = err.Error()
+		s.NextRetryAt = &next
+		s.endSpan(err)
+		s.parent.publishStep(s)
+		s.parent.mainMutex.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.parent.mainMutex.Lock()
+			if s.attached && s.State == StatePending {
+				s.NextRetryAt = nil
+				s.markStopped(ctx.Err().Error(), true, s.parent.now())
+				s.parent.completeIfTerminal()
+			}
+			s.parent.mainMutex.Unlock()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		s.parent.mainMutex.Lock()
+		if !s.attached || s.State != StatePending { // altered while waiting
+			s.parent.mainMutex.Unlock()
+			return err
+		}
+		s.begin(s.parent.startProgress(), s.parent.now())
+		s.parent.publishStep(s)
+		s.parent.mainMutex.Unlock()
+	}
+}
+
+// call calls 'fn', a panic is recovered and returned as an error wrapping ErrStepPanicked.
+func (s *Step) call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrStepPanicked, r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// markStopped transitions the step to StateStopped and publishes it, it should be called while holding the lock.
+func (s *Step) markStopped(reason string, cancelled bool, now time.Time) {
+	s.endPause(now)
+	s.State = StateStopped
+	s.Reason = reason
+	s.Cancelled = cancelled
+	s.DoneAt = &now
+	s.endSpan(errStepStopped(reason, cancelled))
+	s.parent.publishStep(s)
+}
+
+// MarshalJSON is a custom JSON marshaler that automatically computes and append some runtime metadata:
+// the "duration", and the "percent" (0 to 100, always present, 100 once done).
+// If the step data cannot be marshaled, it is replaced by a placeholder instead of failing.
+// A step of a progress is read under the lock, so it can run alongside the changes.
 func (s *Step) MarshalJSON() ([]byte, error) {
 	type alias Step
 	type enriched struct {
 		alias
 		Duration time.Duration `json:"duration,omitempty"`
+		Percent  int           `json:"percent"`
 	}
-	return json.Marshal(&enriched{
-		alias:    (alias)(*s),
-		Duration: s.Duration(),
-	})
+	var ret *enriched
+	if s.parent != nil {
+		s.parent.mainMutex.RLock()
+		ret = &enriched{alias: (alias)(s.clone()), Duration: s.Duration()}
+		s.parent.mainMutex.RUnlock()
+	} else {
+		ret = &enriched{alias: (alias)(*s), Duration: s.Duration()}
+	}
+	switch ret.State {
+	case StateDone:
+		ret.Percent = 100
+	case StateNotStarted:
+		ret.Percent = 0
+	default:
+		ret.Percent = s.parent.percent(ret.Progress) // rounded like Progress.Percent, see WithPercentMode
+	}
+	if ret.Data != nil {
+		if _, err := json.Marshal(ret.Data); err != nil {
+			ret.Data = fmt.Sprintf("<unserializable %T>", ret.Data)
+		}
+	}
+	return json.Marshal(ret)
+}
+
+// Started returns the time the step was started, read under the lock.
+// The boolean is false if the step was never started.
+func (s *Step) Started() (time.Time, bool) {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	if s.StartedAt == nil {
+		return time.Time{}, false
+	}
+	return *s.StartedAt, true
+}
+
+// Finished returns the time the step was done, stopped or failed, read under the lock.
+// The boolean is false if the step is not finished.
+func (s *Step) Finished() (time.Time, bool) {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	if s.DoneAt == nil {
+		return time.Time{}, false
+	}
+	return *s.DoneAt, true
+}
+
+// TimeIn returns the cumulative time spent by the step in 'state', including the time spent in the
+// current state so far, i.e., a long not started time reveals a queueing delay.
+// Only the not started, pending and in progress states are tracked, see TimeInState.
+func (s *Step) TimeIn(state State) time.Duration {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	ret := s.TimeInState[state]
+	if state == s.publishedState && !state.IsTerminal() && !s.stateSince.IsZero() {
+		ret += s.parent.now().Sub(s.stateSince)
+	}
+	return ret
+}
+
+// trackStateTime adds the time spent in the 'previous' state to TimeInState, it should be called while
+// holding the lock, on each transition.
+// The map is replaced instead of updated, because it is shared with the published copies of the step.
+func (s *Step) trackStateTime(previous State, now time.Time) {
+	if previous != "" && !previous.IsTerminal() && !s.stateSince.IsZero() {
+		timeInState := make(map[State]time.Duration, len(s.TimeInState)+1)
+		for state, duration := range s.TimeInState {
+			timeInState[state] = duration
+		}
+		timeInState[previous] += now.Sub(s.stateSince)
+		s.TimeInState = timeInState
+	}
+	s.stateSince = now
+}
+
+// Clone returns a copy of the step, taken under the lock, that is not bound to the progress anymore: it
+// can be kept to be compared later, or passed to another goroutine, without seeing the subsequent changes.
+// The child progress, if any, is cloned too, recursively.
+// The mutators of the copy must not be called.
+func (s *Step) Clone() Step {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	return s.clone()
+}
+
+// clone is the implementation of Clone, it should be called while holding the lock.
+func (s *Step) clone() Step {
+	ret := *s
+	ret.parent = nil
+	ret.attached = false
+	ret.publishTimer = nil
+	ret.stateHooks = nil
+	ret.progressFunc = nil
+	ret.span = nil
+	ret.spanCtx = nil
+	ret.Warnings = append([]string(nil), s.Warnings...)
+	ret.Logs = append([]LogEntry(nil), s.Logs...)
+	ret.DependsOn = append([]string(nil), s.DependsOn...)
+	if s.Labels != nil {
+		ret.Labels = make(map[string]string, len(s.Labels))
+		for key, value := range s.Labels {
+			ret.Labels[key] = value
+		}
+	}
+	if s.Snapshot != nil {
+		snapshot := *s.Snapshot
+		ret.Snapshot = &snapshot
+	}
+	if s.progressFunc != nil || s.Child != nil {
+		ret.Progress = s.currentProgress() // like PercentString
+	}
+	if s.Child != nil {
+		s.Child.mainMutex.RLock()
+		ret.Child = s.Child.clone()
+		s.Child.mainMutex.RUnlock()
+	}
+	return ret
+}
+
+// clone returns a standalone copy of the progress and its steps, without the subscribers, it should be
+// called while holding the lock.
+func (p *Progress) clone() *Progress {
+	ret := &Progress{
+		Name:                p.Name,
+		CreatedAt:           p.CreatedAt,
+		Steps:               make([]*Step, 0, len(p.Steps)),
+		index:               make(map[string]*Step, len(p.Steps)),
+		maxLogs:             p.maxLogs,
+		dynamicSteps:        p.dynamicSteps,
+		customStartProgress: p.customStartProgress,
+		customDoneThreshold: p.customDoneThreshold,
+		phaseLabel:          p.phaseLabel,
+		percentMode:         p.percentMode,
+		stateSortOrder:      p.stateSortOrder,
+		finished:            p.finished,
+		clock:               p.clock,
+		snapshotStepIDs:     p.snapshotStepIDs,
+	}
+	if p.Metadata != nil {
+		ret.Metadata = make(map[string]string, len(p.Metadata))
+		for key, value := range p.Metadata {
+			ret.Metadata[key] = value
+		}
+	}
+	for _, step := range p.Steps {
+		stepCopy := step.clone()
+		stepCopy.parent = ret
+		stepCopy.attached = true
+		ret.Steps = append(ret.Steps, &stepCopy)
+		ret.index[stepCopy.ID] = &stepCopy
+	}
+	return ret
 }
 
 // Duration computes the step duration.
+// It relies on the monotonic clock when available, so it is not affected by wall clock changes, and it is
+// never negative.
 func (s *Step) Duration() time.Duration {
+	return s.durationAt(s.parent.now())
+}
+
+// durationAt is equivalent to Duration, but an in-progress step is measured until 'now'.
+func (s *Step) durationAt(now time.Time) time.Duration {
 	var ret time.Duration
+	if s.StartedAt == nil {
+		return ret
+	}
+	startedAt := *s.StartedAt
 	switch s.State {
 	case StateInProgress:
-		ret = time.Since(*s.StartedAt)
-	case StateDone:
-		ret = s.DoneAt.Sub(*s.StartedAt)
-	case StateNotStarted:
-		// noop
-	case StateStopped:
-		panic(fmt.Sprintf("step cannot be in stopped state (yet!): %s", u.JSON(s)))
+		ret = now.Sub(startedAt)
+		if s.Paused && !s.pausedAt.IsZero() {
+			ret -= now.Sub(s.pausedAt)
+		}
+	case StateDone, StateStopped, StateFailed:
+		if s.DoneAt == nil { // can be missing in unmarshaled steps
+			break
+		}
+		ret = s.DoneAt.Sub(startedAt)
 	default:
-		// noop
+		// not started, pending or unknown state
 	}
-	return ret
+	return nonNegative(ret - s.PausedDuration)
+}
+
+// IsCompletion returns true if the step is the completion event sent to the subscribers when the
+// progress is complete; this event is not a real step, it only carries the final Snapshot.
+func (s *Step) IsCompletion() bool {
+	return s.Snapshot != nil
+}
+
+// PercentString returns the step completion rate as a percentage, i.e., "66%".
+// It is truncated by default, see WithPercentMode; a done step is always "100%".
+func (s *Step) PercentString() string {
+	s.parent.mainMutex.RLock()
+	defer s.parent.mainMutex.RUnlock()
+	return fmt.Sprintf("%d%%", s.percent())
+}
+
+// percent returns the step completion percentage, it should be called while holding the lock.
+func (s *Step) percent() int {
+	if s.State == StateDone {
+		return s.parent.percent(doneProgress)
+	}
+	return s.parent.percent(s.currentProgress())
+}
+
+// currentProgress returns the progress rate of the step, evaluating the child progress (see SetChild) or the
+// progress func (see SetProgressFunc) of an in-progress step; it should be called while holding the lock.
+func (s *Step) currentProgress() float64 {
+	if s.State != StateInProgress {
+		return s.Progress
+	}
+	if s.Child != nil {
+		return s.Child.Progress()
+	}
+	if s.progressFunc == nil {
+		return s.Progress
+	}
+	switch progress := s.progressFunc(); {
+	case progress < notStartedProgress:
+		return notStartedProgress
+	case progress > doneProgress:
+		return doneProgress
+	default:
+		return progress
+	}
+}
+
+// percent converts a progress rate to a percentage, following the configured PercentMode.
+func (p *Progress) percent(progress float64) int {
+	value := progress * 100
+	mode := PercentTruncate
+	if p != nil { // a step that is not part of a progress anymore, see Step.Clone
+		mode = p.percentMode
+	}
+	if mode == PercentTruncate {
+		return int(value)
+	}
+	// absorb the floating-point errors, i.e., 0.3*100 is 30.000000000000004 and should not be ceiled to 31
+	value = math.Round(value*percentPrecision) / percentPrecision
+	switch mode {
+	case PercentRound:
+		return int(math.Round(value))
+	case PercentFloor:
+		return int(math.Floor(value))
+	case PercentCeil:
+		return int(math.Ceil(value))
+	}
+	return int(value)
+}
+
+// breadcrumb returns the title of the step, followed by the path to the deepest in-progress step of its
+// child progresses, if any, with its percentage, i.e., "deploy > uploading (40%)".
+// On each level, the focused step is followed, else the first in-progress one.
+// It should be called while holding the lock of the step progress; the child progresses are locked in turn.
+func (s *Step) breadcrumb() string {
+	parts := []string{s.title()}
+	progress := 0.0
+	for child := s.Child; child != nil; {
+		child.mainMutex.RLock()
+		var active *Step
+		for _, step := range child.Steps {
+			if step.State == StateInProgress && (active == nil || (step.Focused && !active.Focused)) {
+				active = step
+			}
+		}
+		if active == nil {
+			child.mainMutex.RUnlock()
+			break
+		}
+		parts = append(parts, active.title())
+		progress = active.currentProgress()
+		next := active.Child
+		child.mainMutex.RUnlock()
+		child = next
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return fmt.Sprintf("%s (%d%%)", strings.Join(parts, " > "), s.parent.percent(progress))
 }
 
 func (s *Step) title() string {
+	if s.Name != "" {
+		return s.Name
+	}
 	if s.Description != "" {
 		return s.Description
 	}
@@ -461,6 +2949,18 @@ func (s *Step) title() string {
 }
 
 var (
-	ErrStepRequiresID       = errors.New("progress.AddStep requires a non-empty ID as argument")
-	ErrStepIDShouldBeUnique = errors.New("progress.AddStep requires a unique ID as argument")
+	ErrStepRequiresID         = errors.New("progress.AddStep requires a non-empty ID as argument")
+	ErrStepIDShouldBeUnique   = errors.New("progress.AddStep requires a unique ID as argument")
+	ErrStepNotFound           = errors.New("progress: step not found")
+	ErrStepFailed             = errors.New("progress: step failed")
+	ErrStepRetried            = errors.New("progress: step retried")
+	ErrStepMaxAttemptsReached = errors.New("progress: step reached its maximum number of attempts")
+	ErrStepPanicked           = errors.New("progress: step panicked")
+	ErrCyclicChild            = errors.New("progress: child progress would create a cycle")
+	ErrStepDetached           = errors.New("progress: step was removed from its progress")
+	ErrInvalidTransition      = errors.New("progress: invalid step transition")
+	ErrUnknownState           = errors.New("progress: unknown state")
+	ErrUnknownDependency      = errors.New("progress: step depends on an unknown step")
+	ErrCyclicDependency       = errors.New("progress: step dependencies would create a cycle")
+	ErrBuilderRequiresStep    = errors.New("progress.Builder requires a Step before DependsOn or Weight")
 )
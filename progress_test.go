@@ -1,7 +1,13 @@
 package progress_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -293,7 +299,7 @@ func TestFlow(t *testing.T) {
 	}
 
 	// debug
-	// fmt.Println(u.PrettyJSON(prog))
+	// fmt.Println(prog.PrettyJSON())
 }
 
 func TestSubscribe(t *testing.T) {
@@ -319,10 +325,10 @@ func TestSubscribe(t *testing.T) {
 	prog.Get("step3").Start()
 	prog.Get("step1").Done()
 	prog.Get("step3").Done()
-	// fmt.Println(u.PrettyJSON(prog))
+	// fmt.Println(prog.PrettyJSON())
 
 	<-done
-	require.Equal(t, 9, seen)
+	require.Equal(t, 10, seen) // 9 step events + 1 completion event
 }
 
 func TestSubscribe_withConcurrency(t *testing.T) {
@@ -371,7 +377,7 @@ func TestSubscribe_withConcurrency(t *testing.T) {
 	prog.Get("step11").Done()
 	prog.Get("step10").Done()
 	prog.Get("step9").Done()
-	_ = fmt.Sprintf("result: %v", u.PrettyJSON(prog))
+	_ = fmt.Sprintf("result: %v", prog.PrettyJSON())
 
 	<-done
 	// require.Equal(t, 9, seen)
@@ -397,6 +403,7 @@ func TestSubcribe_closeReopen(t *testing.T) {
 	require.NotNil(t, <-ch1)
 	prog.Get("step1").Done()
 	require.NotNil(t, <-ch1)
+	require.True(t, (<-ch1).IsCompletion())
 	require.Nil(t, <-ch1)
 
 	// add a new step, the previous chan should still be closed
@@ -415,6 +422,2637 @@ func TestSubcribe_closeReopen(t *testing.T) {
 	require.NotNil(t, <-ch2)
 	prog.Get("step3").Done()
 	require.NotNil(t, <-ch2)
+	require.True(t, (<-ch2).IsCompletion())
 	require.Nil(t, <-ch2)
 	require.Nil(t, <-ch1)
 }
+
+func TestEach(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	prog.AddStep("step3")
+
+	// full iteration, in order
+	ids := []string{}
+	prog.Each(func(step *progress.Step) bool {
+		ids = append(ids, step.ID)
+		return true
+	})
+	require.Equal(t, []string{"step1", "step2", "step3"}, ids)
+
+	// early stop
+	ids = []string{}
+	prog.Each(func(step *progress.Step) bool {
+		ids = append(ids, step.ID)
+		return step.ID != "step2"
+	})
+	require.Equal(t, []string{"step1", "step2"}, ids)
+
+	// empty progress
+	called := false
+	progress.New().Each(func(*progress.Step) bool {
+		called = true
+		return true
+	})
+	require.False(t, called)
+}
+
+func TestWithPublishInterval(t *testing.T) {
+	prog := progress.New(progress.WithPublishInterval(50 * time.Millisecond))
+	defer prog.Close()
+	ch := prog.Subscribe()
+	step := prog.AddStep("step1")
+	require.Equal(t, progress.StateNotStarted, (<-ch).State)
+
+	const calls = 10000
+	for i := 1; i < calls; i++ {
+		step.SetProgress(float64(i) / calls)
+	}
+
+	// the first call is a state change (not started -> in progress), it's published immediately
+	first := <-ch
+	require.Equal(t, progress.StateInProgress, first.State)
+
+	// the latest value is flushed at the end of the interval
+	var last *progress.Step
+	seen := 1
+	timeout := time.After(time.Second)
+loop:
+	for {
+		select {
+		case last = <-ch:
+			seen++
+			if last.Progress == float64(calls-1)/calls {
+				break loop
+			}
+		case <-timeout:
+			t.Fatal("coalesced event was not flushed")
+		}
+	}
+	require.True(t, seen < 10, "too many events published: %d", seen)
+
+	// terminal changes are published immediately
+	step.Done()
+	require.Equal(t, progress.StateDone, (<-ch).State)
+	require.True(t, (<-ch).IsCompletion())
+	require.Nil(t, <-ch)
+}
+
+func TestDoneCount(t *testing.T) {
+	prog := progress.New()
+	require.Equal(t, 0, prog.DoneCount())
+	require.Equal(t, 0, prog.RemainingCount())
+
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	prog.AddStep("step3")
+	require.Equal(t, 0, prog.DoneCount())
+	require.Equal(t, 3, prog.RemainingCount())
+
+	prog.Get("step1").Start()
+	prog.Get("step2").Done()
+	require.Equal(t, 1, prog.DoneCount())
+	require.Equal(t, 2, prog.RemainingCount())
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, snapshot.Completed, prog.DoneCount())
+	require.Equal(t, snapshot.Total-snapshot.Completed, prog.RemainingCount())
+}
+
+func TestStepName(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1").Start()
+	require.Equal(t, "step1", prog.Snapshot().Doing)
+
+	step.SetDescription("a long description of the first step")
+	require.Equal(t, "a long description of the first step", prog.Snapshot().Doing)
+
+	step.SetName("first")
+	require.Equal(t, "first", prog.Snapshot().Doing)
+	require.Equal(t, "a long description of the first step", step.Description)
+
+	out := u.JSON(step)
+	require.Contains(t, out, `"name":"first"`)
+	require.Contains(t, out, `"description":"a long description of the first step"`)
+}
+
+func TestAddSubStep(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("deploy")
+	require.Nil(t, step.Child)
+
+	upload := step.AddSubStep("upload")
+	require.NotNil(t, step.Child)
+	require.Equal(t, upload, step.Child.Get("upload"))
+
+	step.AddSubStep("restart")
+	require.Len(t, step.Child.Steps, 2)
+	require.Panics(t, func() { step.AddSubStep("upload") })
+
+	upload.Start()
+	require.Equal(t, "upload", step.Child.Snapshot().Doing)
+
+	child := progress.New()
+	step.SetChild(child)
+	require.Equal(t, child, step.Child)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ch := prog.Subscribe()
+	prog.AddStep("step1")
+	require.NotNil(t, <-ch)
+
+	prog.Unsubscribe(ch)
+	prog.Unsubscribe(ch) // should not panic
+	prog.AddStep("step2")
+	_, ok := <-ch
+	require.False(t, ok)
+}
+
+func TestReorder(t *testing.T) {
+	prog := progress.New(progress.WithSnapshotStepIDs())
+	defer prog.Close()
+	prog.AddStep("step1")
+	prog.AddStep("step2").Start()
+	prog.AddStep("step3")
+	prog.AddStep("step4")
+	ids := func() []string {
+		ret := []string{}
+		prog.Each(func(step *progress.Step) bool {
+			ret = append(ret, step.ID)
+			return true
+		})
+		return ret
+	}
+
+	require.NoError(t, prog.Reorder("step3", "step1"))
+	require.Equal(t, []string{"step3", "step1", "step2", "step4"}, ids())
+	require.Equal(t, progress.StateInProgress, prog.Get("step2").State)
+
+	require.NoError(t, prog.Reorder())
+	require.Equal(t, []string{"step3", "step1", "step2", "step4"}, ids())
+
+	require.Equal(t, progress.ErrStepNotFound, prog.Reorder("step4", "unknown"))
+	require.Equal(t, []string{"step3", "step1", "step2", "step4"}, ids())
+
+	// the subscribers are notified, with an event only if Snapshot.Doing changed
+	prog.Get("step4").Start()
+	ch := prog.Subscribe()
+	snapshots := prog.SubscribeSnapshots()
+	require.NoError(t, prog.Reorder("step1", "step3"))
+	require.Equal(t, []string{"step1", "step3", "step2", "step4"}, (<-snapshots).RemainingSteps)
+	require.NoError(t, prog.Reorder("step4"))
+	snapshot := <-snapshots
+	require.Equal(t, "step4, step2", snapshot.Doing)
+	require.Equal(t, "step4", (<-ch).ID)
+	require.Equal(t, "step2", (<-ch).ID)
+	require.NoError(t, prog.Reorder("step4"))
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event: %v", event)
+	case snapshot := <-snapshots:
+		t.Fatalf("unexpected snapshot: %v", snapshot)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSkippedSteps(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("skipped1")
+	prog.AddStep("real")
+	prog.AddStep("skipped2")
+
+	// a step marked as done without being started is skipped
+	prog.Get("skipped1").Done()
+	require.True(t, prog.Get("skipped1").Skipped)
+	require.Zero(t, prog.Get("skipped1").Duration())
+
+	time.Sleep(200 * time.Millisecond)
+	prog.Get("real").Start()
+	require.False(t, prog.Get("real").Skipped)
+	time.Sleep(100 * time.Millisecond)
+	prog.Get("real").Done()
+	prog.Get("skipped2").Done()
+	require.True(t, prog.Get("skipped2").Skipped)
+
+	// the aggregate duration only reflects the real work
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateDone, snapshot.State)
+	require.Equal(t, prog.Get("real").StartedAt, snapshot.StartedAt)
+	require.True(t, snapshot.TotalDuration >= 100*time.Millisecond && snapshot.TotalDuration < 200*time.Millisecond)
+}
+
+func TestSkippedSteps_only(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").Done()
+	prog.AddStep("step2").Done()
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateDone, snapshot.State)
+	require.NotNil(t, snapshot.StartedAt)
+	require.True(t, snapshot.TotalDuration >= 0 && snapshot.TotalDuration < 50*time.Millisecond)
+}
+
+func TestSetProgressFromCounts(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+	prog.AddStep("step2")
+
+	// total==0 is not applicable
+	step.SetProgressFromCounts(0, 0)
+	require.Equal(t, progress.StateNotStarted, step.State)
+	require.Equal(t, float64(0), step.Progress)
+
+	step.SetProgressFromCounts(37, 100)
+	require.Equal(t, progress.StateInProgress, step.State)
+	require.Equal(t, 0.37, step.Progress)
+	require.Equal(t, 37, step.Count)
+	require.Equal(t, 100, step.Total)
+	out := u.JSON(step)
+	require.Contains(t, out, `"count":37`)
+	require.Contains(t, out, `"total":100`)
+
+	step.SetProgressFromCounts(100, 100)
+	require.Equal(t, progress.StateDone, step.State)
+	require.Equal(t, 100, step.Count)
+
+	// updating the counts of a done step is allowed
+	step.SetProgressFromCounts(101, 100)
+	require.Equal(t, progress.StateDone, step.State)
+	require.Equal(t, 101, step.Count)
+}
+
+func TestSetProgressFromCounts_concurrent(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		prog := progress.New()
+		step := prog.AddStep("step1")
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				step.SetProgressFromCounts(10, 10) // only the first call marks the step as done
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, progress.StateDone, prog.Get("step1").State)
+	}
+}
+
+func TestWithName(t *testing.T) {
+	prog := progress.New(
+		progress.WithName("deploy-prod"),
+		progress.WithMetadata("region", "eu-west"),
+		progress.WithMetadata("version", "v1.2.3"),
+	)
+	require.Equal(t, "deploy-prod", prog.Name)
+	require.Equal(t, map[string]string{"region": "eu-west", "version": "v1.2.3"}, prog.Metadata)
+
+	out := prog.JSON()
+	require.Contains(t, out, `"name":"deploy-prod"`)
+	require.Contains(t, out, `"metadata":{"region":"eu-west","version":"v1.2.3"}`)
+	require.Contains(t, out, `"snapshot":`)
+
+	require.NotContains(t, progress.New().JSON(), `"name"`)
+}
+
+func TestAggregateSnapshot(t *testing.T) {
+	// empty inputs
+	require.Equal(t, progress.StateNotStarted, progress.AggregateSnapshot().State)
+	require.Equal(t, progress.StateNotStarted, progress.AggregateSnapshot(nil, progress.New()).State)
+
+	prog1 := progress.New()
+	prog1.AddStep("step1").Done()
+	prog1.AddStep("step2")
+	prog2 := progress.New()
+	prog2.AddStep("step1")
+	prog2.AddStep("step2")
+	prog2.AddStep("step3").Start()
+	prog2.AddStep("step4")
+
+	snapshot := progress.AggregateSnapshot(prog1, prog2)
+	require.Equal(t, progress.StateInProgress, snapshot.State)
+	require.Equal(t, 6, snapshot.Total)
+	require.Equal(t, 1, snapshot.Completed)
+	require.Equal(t, 1, snapshot.InProgress)
+	require.Equal(t, 4, snapshot.NotStarted)
+	require.Equal(t, "step3", snapshot.Doing)
+	require.Equal(t, 0.25, snapshot.Progress) // (1 + 0.5) / 6
+
+	// the view is computed again on each call
+	prog1.Get("step2").Done()
+	require.Equal(t, 2, progress.AggregateSnapshot(prog1, prog2).Completed)
+	require.Equal(t, progress.StateDone, progress.AggregateSnapshot(prog1).State)
+}
+
+func TestFinish(t *testing.T) {
+	// an empty progress is not started by default
+	prog := progress.New()
+	require.Equal(t, progress.StateNotStarted, prog.Snapshot().State)
+	require.Equal(t, float64(0), prog.Progress())
+
+	// a finished empty progress is done
+	ch := prog.Subscribe()
+	prog.Finish()
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateDone, snapshot.State)
+	require.Equal(t, float64(1), snapshot.Progress)
+	require.Equal(t, snapshot.Progress, prog.Progress())
+	require.Equal(t, progress.StateDone, (<-ch).Snapshot.State)
+	require.Nil(t, <-ch)
+
+	// adding steps after Finish gives back the control to the steps
+	prog.AddStep("step1")
+	require.Equal(t, progress.StateNotStarted, prog.Snapshot().State)
+	require.Equal(t, float64(0), prog.Progress())
+	prog.Get("step1").Done()
+	require.Equal(t, progress.StateDone, prog.Snapshot().State)
+
+	// finishing a progress with pending steps only affects the subscribers of a done progress
+	prog = progress.New()
+	defer prog.Close()
+	prog.AddStep("step1")
+	prog.Finish()
+	require.Equal(t, progress.StateNotStarted, prog.Snapshot().State)
+}
+
+func TestCancel(t *testing.T) {
+	prog := progress.New()
+	ch := prog.Subscribe()
+	prog.AddStep("step1").Start()
+	prog.AddStep("step2").Start()
+	prog.AddStep("step3")
+	prog.AddStep("step4")
+
+	prog.Get("step1").SetProgress(0.8).Cancel("cancelled by user")
+	step1 := prog.Get("step1")
+	require.Equal(t, progress.StateStopped, step1.State)
+	require.True(t, step1.Cancelled)
+	require.Equal(t, "cancelled by user", step1.Reason)
+	require.NotNil(t, step1.DoneAt)
+	require.True(t, step1.Duration() >= 0)
+	require.Panics(t, func() { step1.Cancel("again") })
+	require.Panics(t, func() { step1.Stop("again") })
+
+	prog.Get("step2").Stop("timed out")
+	step2 := prog.Get("step2")
+	require.Equal(t, progress.StateStopped, step2.State)
+	require.False(t, step2.Cancelled)
+	require.Equal(t, "timed out", step2.Reason)
+
+	prog.Get("step3").Done()
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateStopped, snapshot.State)
+	require.Equal(t, 2, snapshot.Stopped)
+	require.Equal(t, 1, snapshot.Cancelled)
+	require.Equal(t, 1, snapshot.Completed)
+	require.Equal(t, 1, snapshot.NotStarted)
+	require.Equal(t, 0, snapshot.InProgress)
+	require.Equal(t, "", snapshot.Doing)
+	require.Equal(t, 0.575, snapshot.Progress) // (0.8 + 0.5 + 1) / 4
+	require.Equal(t, snapshot.Progress, prog.Progress())
+
+	// a step can be cancelled without being started, the progress is now terminal
+	prog.Get("step4").Cancel("")
+	require.Zero(t, prog.Get("step4").Duration())
+	snapshot = prog.Snapshot()
+	require.Equal(t, progress.StateStopped, snapshot.State)
+	require.Equal(t, 2, snapshot.Cancelled)
+	for step := range ch {
+		if step == nil {
+			break
+		}
+	}
+}
+
+func TestCancel_neverStarted(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").Cancel("not needed")
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateStopped, snapshot.State)
+	require.Zero(t, snapshot.TotalDuration)
+	require.Equal(t, float64(0), snapshot.Progress)
+}
+
+func TestStepMarshalJSON_unserializableData(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").SetData(make(chan int))
+	prog.AddStep("step2").SetData(func() {})
+	prog.AddStep("step3").SetData(42)
+
+	out, err := json.Marshal(prog)
+	require.NoError(t, err)
+	var decoded struct {
+		Steps []struct {
+			Data interface{} `json:"data"`
+		} `json:"steps"`
+		Snapshot progress.Snapshot `json:"snapshot"`
+	}
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Len(t, decoded.Steps, 3)
+	require.Equal(t, "<unserializable chan int>", decoded.Steps[0].Data)
+	require.Equal(t, "<unserializable func()>", decoded.Steps[1].Data)
+	require.Equal(t, float64(42), decoded.Steps[2].Data)
+	require.Equal(t, 3, decoded.Snapshot.Total)
+}
+
+func TestSubscribeFiltered(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ch := prog.SubscribeFiltered(func(step *progress.Step) bool {
+		return step.ID == "step2"
+	})
+	all := prog.SubscribeFiltered(nil)
+
+	prog.AddStep("step1").Start()
+	prog.AddStep("step2").Start()
+	prog.Get("step1").Done()
+	prog.Get("step2").Done()
+
+	seen := []progress.State{}
+	for step := range ch {
+		if step.IsCompletion() {
+			continue
+		}
+		require.Equal(t, "step2", step.ID)
+		seen = append(seen, step.State)
+	}
+	require.Equal(t, []progress.State{progress.StateNotStarted, progress.StateInProgress, progress.StateDone}, seen)
+
+	count := 0
+	for range all {
+		count++
+	}
+	require.Equal(t, 7, count) // 6 step events + 1 completion event
+}
+
+func TestDroppedEvents(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	slow := prog.Subscribe()
+	fast := prog.Subscribe()
+	go func() {
+		for range fast { // consume everything
+		}
+	}()
+	require.Equal(t, 0, prog.DroppedEvents())
+
+	// fill the slow subscriber's buffer, then publish one more event
+	step := prog.AddStep("step1")
+	for i := 1; i < 42; i++ {
+		step.SetProgress(float64(i) / 100)
+	}
+	require.Eventually(t, func() bool { return len(slow) == 42 }, time.Second, time.Millisecond)
+	require.Equal(t, 0, prog.DroppedEvents())
+
+	step.SetDescription("dropped") // dropped once the publish timeout is reached
+	require.Eventually(t, func() bool { return prog.DroppedEvents() == 1 }, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, prog.SubscriberDroppedEvents(slow))
+	require.Equal(t, 0, prog.SubscriberDroppedEvents(fast))
+}
+
+func TestDroppedEvents_terminal(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	slow := prog.Subscribe()
+
+	// fill the slow subscriber's buffer
+	step := prog.AddStep("step1")
+	for i := 1; i < cap(slow); i++ {
+		step.SetProgress(float64(i) / 100)
+	}
+	require.Eventually(t, func() bool { return len(slow) == cap(slow) }, time.Second, time.Millisecond)
+
+	step.SetDescription("dropped") // dropped once the publish timeout is reached
+	step.Done()                    // never dropped
+	require.Eventually(t, func() bool { return prog.DroppedEvents() == 1 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 1, prog.DroppedEvents())
+
+	events := []*progress.Step{}
+	for event := range slow {
+		events = append(events, event)
+	}
+	require.Len(t, events, cap(slow)+2)
+	require.Equal(t, progress.StateDone, events[len(events)-2].State)
+	require.Equal(t, "dropped", events[len(events)-2].Description)
+	require.True(t, events[len(events)-1].IsCompletion())
+}
+
+func TestDroppedEvents_terminalStalledSubscriber(t *testing.T) {
+	prog := progress.New(progress.WithDynamicSteps())
+	defer prog.Close()
+	stalled := prog.Subscribe() // never read
+	fast := prog.Subscribe()
+
+	step := prog.AddStep("a")
+	for i := 1; i < cap(stalled); i++ {
+		step.SetProgress(float64(i) / 100)
+	}
+	step.Done()       // queued for the stalled subscriber
+	step.Log("after") // not a transition, droppable
+	prog.AddStep("b").Start()
+
+	deadline := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case event := <-fast:
+			if event.ID == "b" && event.State == progress.StateInProgress {
+				return
+			}
+		case <-deadline:
+			t.Fatal("the stalled subscriber blocked the other ones")
+		}
+	}
+}
+
+func TestDroppedEvents_stalledSubscriberTimeout(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	stalled := prog.Subscribe() // never read
+	fast := prog.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for event := range fast {
+			if event.IsCompletion() {
+				close(done)
+			}
+		}
+	}()
+
+	step := prog.AddStep("step1")
+	for i := 1; i < cap(stalled); i++ {
+		step.SetProgress(float64(i) / 100)
+	}
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		step.SetProgress(0.5 + float64(i)/1000) // only the first one waits for the stalled subscriber
+	}
+	step.Done()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the stalled subscriber delayed the completion of the other ones")
+	}
+	require.True(t, time.Since(start) < 2*time.Second, time.Since(start))
+	dropped := prog.DroppedEvents() // the others were coalesced while the dispatcher was waiting
+	require.True(t, dropped >= 1 && dropped <= 60, dropped)
+}
+
+func TestDroppedEvents_coalesced(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	stalled := prog.Subscribe() // never read
+	fast := prog.Subscribe()
+
+	step := prog.AddStep("step1")
+	for i := 1; i < cap(stalled); i++ {
+		step.SetProgress(float64(i) / 100)
+	}
+	for len(fast) > 0 {
+		<-fast
+	}
+	// queued while the dispatcher waits for the stalled subscriber, only the latest one is kept
+	for i := 0; i < 1000; i++ {
+		step.SetProgress(0.5 + float64(i)/10000)
+	}
+
+	var received []*progress.Step
+	timeout := time.After(3 * time.Second)
+	for len(received) == 0 || received[len(received)-1].Progress != 0.5999 {
+		select {
+		case event := <-fast:
+			received = append(received, event)
+		case <-timeout:
+			t.Fatal("the latest event was not received")
+		}
+	}
+	require.True(t, len(received) < 100, len(received))
+}
+
+func TestDroppedEvents_terminalUnsubscribe(t *testing.T) {
+	prog := progress.New()
+	slow := prog.Subscribe()
+	step := prog.AddStep("step1")
+	for i := 1; i < cap(slow); i++ {
+		step.SetProgress(float64(i) / 100)
+	}
+	step.Done() // waits until the subscriber is removed
+
+	time.Sleep(50 * time.Millisecond)
+	prog.Unsubscribe(slow)
+	count := 0
+	for range slow {
+		count++
+	}
+	// the pending terminal events may be received or given up, but the dispatcher is released
+	require.True(t, count >= cap(slow) && count <= cap(slow)+2)
+	prog.Close()
+}
+
+func TestAddWarning(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ch := prog.Subscribe()
+	step := prog.AddStep("step1").Start()
+	prog.AddStep("step2")
+	require.NotNil(t, <-ch)
+	require.NotNil(t, <-ch)
+	require.NotNil(t, <-ch)
+
+	step.AddWarning("disk almost full").AddWarning("slow network")
+	require.Equal(t, []string{"disk almost full"}, (<-ch).Warnings)
+	require.Equal(t, []string{"disk almost full", "slow network"}, (<-ch).Warnings)
+	require.Equal(t, progress.StateInProgress, step.State)
+
+	step.Done()
+	prog.Get("step2").AddWarning("skipped")
+	require.Equal(t, progress.StateDone, step.State)
+	snapshot := prog.Snapshot()
+	require.Equal(t, 3, snapshot.Warnings)
+	require.Contains(t, u.JSON(step), `"warnings":["disk almost full","slow network"]`)
+}
+
+func BenchmarkGet(b *testing.B) {
+	prog := progress.New()
+	for i := 0; i < 10000; i++ {
+		prog.AddStep(fmt.Sprintf("step%d", i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = prog.Get("step9999")
+	}
+}
+
+func BenchmarkAddStep(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		prog := progress.New()
+		for j := 0; j < 10000; j++ {
+			prog.AddStep(fmt.Sprintf("step%d", j))
+		}
+	}
+}
+
+func TestPercentString(t *testing.T) {
+	prog := progress.New()
+	require.Equal(t, "0%", prog.PercentString())
+
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	prog.AddStep("step3")
+	require.Equal(t, "0%", prog.PercentString())
+	require.Equal(t, "0%", prog.Get("step1").PercentString())
+
+	prog.Get("step1").Done()
+	prog.Get("step2").Done()
+	require.Equal(t, "66%", prog.PercentString()) // truncated, like int(snapshot.Progress*100)
+	require.Equal(t, "100%", prog.Get("step1").PercentString())
+
+	prog.Get("step3").SetProgress(0.337)
+	require.Equal(t, "33%", prog.Get("step3").PercentString())
+	require.Equal(t, "77%", prog.PercentString())
+
+	prog.Get("step3").Done()
+	require.Equal(t, "100%", prog.PercentString())
+}
+
+func TestWithPercentMode(t *testing.T) {
+	cases := []struct {
+		mode                               progress.PercentMode
+		twoThirds, third, tiny, fiftySeven string
+	}{
+		{progress.PercentTruncate, "66%", "33%", "0%", "56%"}, // 0.57*100 is 56.99999999999999
+		{progress.PercentRound, "67%", "33%", "0%", "57%"},
+		{progress.PercentFloor, "66%", "33%", "0%", "57%"},
+		{progress.PercentCeil, "67%", "34%", "1%", "57%"},
+	}
+	for _, tc := range cases {
+		prog := progress.New(progress.WithPercentMode(tc.mode), progress.WithSteps("step1", "step2", "step3"))
+		prog.Get("step1").Done()
+		prog.Get("step2").Done()
+		require.Equal(t, tc.twoThirds, prog.PercentString(), tc.mode)
+		require.Equal(t, tc.twoThirds, fmt.Sprintf("%d%%", prog.Percent()), tc.mode)
+
+		step := prog.Get("step3").SetProgress(1.0 / 3)
+		require.Equal(t, tc.third, step.PercentString(), tc.mode)
+		step.SetProgress(0.001)
+		require.Equal(t, tc.tiny, step.PercentString(), tc.mode)
+
+		// no floating-point artifacts, 0.3*100 is 30.000000000000004
+		step.SetProgress(0.3)
+		require.Equal(t, "30%", step.PercentString(), tc.mode)
+		step.SetProgress(0.57)
+		require.Equal(t, tc.fiftySeven, step.PercentString(), tc.mode)
+		step.Done()
+		require.Equal(t, "100%", prog.PercentString(), tc.mode)
+	}
+}
+
+func TestPercentString_floatRounding(t *testing.T) {
+	prog := progress.New()
+	for i := 0; i < 10; i++ {
+		prog.AddStep(fmt.Sprintf("step%d", i)).Done()
+	}
+	require.Equal(t, float64(1), prog.Progress())
+	require.Equal(t, "100%", prog.PercentString())
+}
+
+func TestWithPersistentSubscribers(t *testing.T) {
+	prog := progress.New(progress.WithPersistentSubscribers())
+	ch := prog.Subscribe()
+
+	// first run
+	prog.AddStep("step1")
+	require.NotNil(t, <-ch)
+	prog.Get("step1").Start()
+	require.NotNil(t, <-ch)
+	prog.Get("step1").Done()
+	require.NotNil(t, <-ch)
+	require.True(t, (<-ch).IsCompletion())
+	step, ok := <-ch
+	require.Nil(t, step) // run complete
+	require.True(t, ok)
+
+	// the no-op changes of a complete run don't complete it again
+	prog.Abort("noop")
+	prog.Finish()
+	require.Equal(t, progress.ErrStepNotFound, prog.RemoveStep("unknown"))
+	select {
+	case step := <-ch:
+		t.Fatalf("unexpected event: %v", step)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// second run with the same chan
+	prog.AddStep("step2")
+	require.Equal(t, "step2", (<-ch).ID)
+	prog.Get("step2").Done()
+	require.Equal(t, progress.StateDone, (<-ch).State)
+	require.True(t, (<-ch).IsCompletion())
+	step, ok = <-ch
+	require.Nil(t, step)
+	require.True(t, ok)
+
+	// Close truly closes the chan
+	prog.Close()
+	_, ok = <-ch
+	require.False(t, ok)
+}
+
+func TestStepData(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+
+	value, ok := progress.StepData[int](step)
+	require.False(t, ok)
+	require.Zero(t, value)
+
+	step.SetData(42)
+	value, ok = progress.StepData[int](step)
+	require.True(t, ok)
+	require.Equal(t, 42, value)
+
+	str, ok := progress.StepData[string](step)
+	require.False(t, ok)
+	require.Empty(t, str)
+
+	step.SetData([]string{"hello", "world"})
+	list, ok := progress.StepData[[]string](step)
+	require.True(t, ok)
+	require.Equal(t, []string{"hello", "world"}, list)
+}
+
+func TestAddStepAfterBefore(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ids := func() []string {
+		ret := []string{}
+		prog.Each(func(step *progress.Step) bool {
+			ret = append(ret, step.ID)
+			return true
+		})
+		return ret
+	}
+	ch := prog.Subscribe()
+	prog.AddStep("build")
+	prog.AddStep("deploy")
+
+	step, err := prog.AddStepAfter("build", "test")
+	require.NoError(t, err)
+	require.Equal(t, "test", step.ID)
+	require.Equal(t, []string{"build", "test", "deploy"}, ids())
+
+	_, err = prog.AddStepAfter("deploy", "notify")
+	require.NoError(t, err)
+	_, err = prog.AddStepBefore("build", "checkout")
+	require.NoError(t, err)
+	_, err = prog.AddStepBefore("deploy", "package")
+	require.NoError(t, err)
+	require.Equal(t, []string{"checkout", "build", "test", "package", "deploy", "notify"}, ids())
+	require.NotNil(t, prog.Get("package"))
+
+	// errors
+	_, err = prog.AddStepAfter("unknown", "new")
+	require.Equal(t, progress.ErrStepNotFound, err)
+	_, err = prog.AddStepBefore("build", "test")
+	require.Equal(t, progress.ErrStepIDShouldBeUnique, err)
+	_, err = prog.AddStepBefore("build", "")
+	require.Equal(t, progress.ErrStepRequiresID, err)
+	require.Len(t, ids(), 6)
+
+	// the additions are published
+	require.Eventually(t, func() bool { return len(ch) == 6 }, time.Second, time.Millisecond)
+}
+
+func TestStartProgress(t *testing.T) {
+	// default
+	prog := progress.New()
+	prog.AddStep("step1").Start()
+	prog.AddStep("step2")
+	require.Equal(t, 0.5, prog.Get("step1").Progress)
+	require.Equal(t, 0.25, prog.Progress())
+
+	// per-step
+	prog.Get("step2").StartWith(0.1)
+	require.Equal(t, progress.StateInProgress, prog.Get("step2").State)
+	require.Equal(t, 0.3, prog.Progress())
+	require.Panics(t, func() { prog.Get("step2").StartWith(0.2) })
+
+	// per-progress
+	prog = progress.New(progress.WithStartProgress(0))
+	prog.AddStep("step1").Start()
+	prog.AddStep("step2")
+	require.Equal(t, progress.StateInProgress, prog.Get("step1").State)
+	require.Equal(t, float64(0), prog.Progress())
+	prog.Get("step2").SetAsCurrent()
+	require.Equal(t, float64(0), prog.Get("step2").Progress)
+	require.Equal(t, 0.5, prog.Progress())
+}
+
+func TestWithPhaseLabel(t *testing.T) {
+	// disabled by default
+	prog := progress.New()
+	prog.AddStep("step1").SetLabel("phase", "build")
+	require.Nil(t, prog.Snapshot().Phases)
+
+	prog = progress.New(progress.WithPhaseLabel("phase"))
+	prog.AddStep("compile").SetLabel("phase", "build").Done()
+	prog.AddStep("lint").SetLabel("phase", "build").SetLabel("owner", "ci").Start()
+	prog.AddStep("upload").SetLabel("phase", "deploy")
+	prog.AddStep("notify") // no phase
+	require.Equal(t, map[string]string{"phase": "build", "owner": "ci"}, prog.Get("lint").Labels)
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, map[string]progress.PhaseStats{
+		"build":  {Completed: 1, Total: 2},
+		"deploy": {Completed: 0, Total: 1},
+	}, snapshot.Phases)
+	require.Equal(t, 4, snapshot.Total)
+}
+
+func TestProgress_concurrency(t *testing.T) {
+	prog := progress.New()
+	for i := 0; i < 20; i++ {
+		prog.AddStep(fmt.Sprintf("step%d", i))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(step *progress.Step) {
+			defer wg.Done()
+			for j := 1; j < 100; j++ {
+				step.SetProgress(float64(j) / 100)
+			}
+			step.Done()
+		}(prog.Get(fmt.Sprintf("step%d", i)))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		value := prog.Progress()
+		require.True(t, value >= 0 && value <= 1, "out of bounds: %v", value)
+		select {
+		case <-done:
+			require.Equal(t, float64(1), prog.Progress())
+			return
+		default:
+		}
+	}
+}
+
+func TestProgress_clamp(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").SetProgress(3)
+	prog.AddStep("step2").SetProgress(0.5)
+	require.Equal(t, float64(1), prog.Progress())
+	prog.Get("step1").SetProgress(-8)
+	require.Equal(t, float64(0), prog.Progress())
+}
+
+func TestSetProgress_zero(t *testing.T) {
+	prog := progress.New()
+
+	// a step that was never started stays not started
+	step1 := prog.AddStep("step1").SetProgress(0)
+	require.Equal(t, progress.StateNotStarted, step1.State)
+	require.Nil(t, step1.StartedAt)
+
+	// a started step stays in progress at 0%
+	step2 := prog.AddStep("step2").SetProgress(0.4).SetProgress(0)
+	require.Equal(t, progress.StateInProgress, step2.State)
+	require.Equal(t, float64(0), step2.Progress)
+	require.NotNil(t, step2.StartedAt)
+
+	step3 := prog.AddStep("step3").Start().SetProgress(0)
+	require.Equal(t, progress.StateInProgress, step3.State)
+	require.Equal(t, "step2, step3", prog.Snapshot().Doing)
+}
+
+func TestAbort(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	prog.AddStep("step1").Done()
+	prog.AddStep("step2").Start()
+	prog.AddStep("step3").SetProgress(0.3)
+	prog.AddStep("step4")
+
+	snapshot := prog.Abort("job cancelled")
+	require.Equal(t, progress.StateStopped, snapshot.State)
+	require.Equal(t, 1, snapshot.Completed)
+	require.Equal(t, 2, snapshot.Stopped)
+	require.Equal(t, 1, snapshot.NotStarted)
+	require.Equal(t, 0, snapshot.InProgress)
+	for _, id := range []string{"step2", "step3"} {
+		step := prog.Get(id)
+		require.Equal(t, progress.StateStopped, step.State)
+		require.Equal(t, "job cancelled", step.Reason)
+		require.False(t, step.Cancelled)
+	}
+	require.Equal(t, progress.StateDone, prog.Get("step1").State)
+	require.Equal(t, progress.StateNotStarted, prog.Get("step4").State)
+}
+
+func TestAbortAll(t *testing.T) {
+	prog := progress.New()
+	ch := prog.Subscribe()
+	prog.AddStep("step1")
+	prog.AddStep("step2").Start()
+	prog.AddStep("step3")
+	prog.Get("step1").Done()
+
+	snapshot := prog.AbortAll("shutting down")
+	require.Equal(t, progress.StateStopped, snapshot.State)
+	require.Equal(t, 2, snapshot.Stopped)
+	require.Equal(t, 0, snapshot.NotStarted)
+	require.Equal(t, "shutting down", prog.Get("step3").Reason)
+
+	// every step is terminal, the subscribers are completed
+	events := 0
+	var last *progress.Step
+	for step := range ch {
+		events++
+		last = step
+	}
+	require.Equal(t, 8, events) // 3 added, 1 done, 1 started, 2 stopped, 1 completion
+	require.True(t, last.IsCompletion())
+}
+
+func TestRetryOrFail(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1").SetMaxAttempts(2)
+	prog.AddStep("step2")
+	require.Equal(t, 0, step.Attempts)
+
+	step.Start()
+	require.Equal(t, 1, step.Attempts)
+	step.SetProgress(0.8)
+
+	// an attempt is left, the step is restarted
+	step.RetryOrFail()
+	require.Equal(t, progress.StateInProgress, step.State)
+	require.Equal(t, 2, step.Attempts)
+	require.Equal(t, 0.5, step.Progress) // reset to the start progress
+	require.Empty(t, step.Error)
+
+	// attempts exhausted, the step is failed
+	step.RetryOrFail()
+	require.Equal(t, progress.StateFailed, step.State)
+	require.Equal(t, 2, step.Attempts)
+	require.Equal(t, progress.ErrStepMaxAttemptsReached.Error(), step.Error)
+	require.NotNil(t, step.DoneAt)
+	step.RetryOrFail() // no-op on a failed step
+	require.Equal(t, 2, step.Attempts)
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, 1, snapshot.Failed)
+	require.Equal(t, progress.StateFailed, snapshot.State)
+
+	out, err := json.Marshal(step)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, 2.0, decoded["attempts"])
+	require.Equal(t, 2.0, decoded["max_attempts"])
+	require.Equal(t, "failed", decoded["state"])
+
+	// a failed step can be started again
+	step.Start()
+	require.Equal(t, 3, step.Attempts)
+	require.Nil(t, step.DoneAt)
+	require.Empty(t, step.Error)
+
+	require.Panics(t, func() { prog.Get("step2").Done().RetryOrFail() })
+}
+
+func TestRetryOrFail_concurrent(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		prog := progress.New()
+		step := prog.AddStep("step1").Start() // no attempt left
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				step.RetryOrFail() // only the first call fails the step
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, progress.StateFailed, prog.Get("step1").State)
+	}
+}
+func TestFail(t *testing.T) {
+	prog := progress.New()
+	ch := prog.Subscribe()
+	step := prog.AddStep("step1").Start()
+	step.Fail(nil)
+	require.Equal(t, progress.StateFailed, step.State)
+	require.Equal(t, progress.ErrStepFailed.Error(), step.Error)
+	require.Panics(t, func() { step.Fail(nil) })
+
+	events := 0
+	var last *progress.Step
+	for evt := range ch {
+		events++
+		last = evt
+	}
+	require.Equal(t, 4, events) // added, started, failed, completion
+	require.True(t, last.IsCompletion())
+	require.Equal(t, progress.StateFailed, last.Snapshot.State)
+}
+
+func TestPublish_stalledSubscriber(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	stalled := prog.Subscribe() // never consumed
+	step := prog.AddStep("step1")
+	for i := 0; i < 100; i++ { // way more than the subscriber buffer
+		step.SetProgress(float64(i) / 100)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = prog.Snapshot()
+		_ = prog.Get("step1")
+		step.SetDescription("not blocked")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond): // less than the publish timeout
+		t.Fatal("the progress is blocked by a stalled subscriber")
+	}
+
+	// the events are still delivered in order
+	prev := -1.0
+	for i := 0; i < 10; i++ {
+		evt := <-stalled
+		require.GreaterOrEqual(t, evt.Progress, prev)
+		prev = evt.Progress
+	}
+}
+
+func TestLenHas(t *testing.T) {
+	prog := progress.New()
+	require.Equal(t, 0, prog.Len())
+	require.False(t, prog.Has("step1"))
+	require.False(t, prog.Has(""))
+
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	require.Equal(t, 2, prog.Len())
+	require.True(t, prog.Has("step1"))
+	require.False(t, prog.Has("step3"))
+}
+
+func TestPend(t *testing.T) {
+	prog := progress.New()
+	step1 := prog.AddStep("step1").Pend()
+	prog.AddStep("step2")
+	require.Equal(t, progress.StatePending, step1.State)
+	require.Nil(t, step1.StartedAt)
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateInProgress, snapshot.State)
+	require.Equal(t, 1, snapshot.Pending)
+	require.Equal(t, 1, snapshot.NotStarted)
+	require.Equal(t, 0, snapshot.InProgress)
+	require.Equal(t, 0.0, snapshot.Progress)
+	require.Equal(t, time.Duration(0), snapshot.TotalDuration)
+
+	step1.SetProgress(0) // stays pending
+	require.Equal(t, progress.StatePending, step1.State)
+
+	step1.Start()
+	require.Equal(t, progress.StateInProgress, step1.State)
+	require.Equal(t, 0, prog.Snapshot().Pending)
+	require.Panics(t, func() { step1.Pend() })
+
+	prog.Get("step2").Pend()
+	snapshot = prog.AbortAll("shutdown")
+	require.Equal(t, 2, snapshot.Stopped)
+
+	// the outcome of a stopped or failed step is cleared
+	step2 := prog.Get("step2").Pend()
+	require.Equal(t, progress.StatePending, step2.State)
+	require.False(t, step2.Cancelled)
+	require.Empty(t, step2.Reason)
+	require.Nil(t, step2.DoneAt)
+	step2.Start().Fail(errors.New("boom"))
+	step2.Pend()
+	require.Empty(t, step2.Error)
+	require.Equal(t, 1, prog.Snapshot().Pending)
+	require.Equal(t, 0, prog.Snapshot().Failed)
+}
+
+func TestSince(t *testing.T) {
+	prog := progress.New()
+	before := time.Now()
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	require.NotNil(t, prog.Get("step1").UpdatedAt)
+
+	steps, next := prog.Since(before)
+	require.Len(t, steps, 2)
+	require.Equal(t, "step1", steps[0].ID)
+	require.False(t, next.Before(*steps[1].UpdatedAt))
+
+	steps, next = prog.Since(next)
+	require.Len(t, steps, 0)
+
+	time.Sleep(time.Millisecond)
+	prog.Get("step2").Start()
+	steps, _ = prog.Since(next)
+	require.Len(t, steps, 1)
+	require.Equal(t, "step2", steps[0].ID)
+	require.Equal(t, progress.StateInProgress, steps[0].State)
+}
+
+func TestWithStep(t *testing.T) {
+	prog := progress.New(
+		progress.WithStep("build"),
+		progress.WithSteps("test", "deploy"),
+	)
+	require.Equal(t, 3, prog.Len())
+	ids := []string{}
+	for _, step := range prog.Steps {
+		ids = append(ids, step.ID)
+	}
+	require.Equal(t, []string{"build", "test", "deploy"}, ids)
+	require.Equal(t, progress.StateNotStarted, prog.Get("deploy").State)
+
+	require.Panics(t, func() { progress.New(progress.WithSteps("build", "build")) })
+	require.Panics(t, func() { progress.New(progress.WithStep("")) })
+}
+
+func TestStepRun(t *testing.T) {
+	prog := progress.New()
+	ctx := context.Background()
+
+	err := prog.AddStep("ok").Run(ctx, func(context.Context) error {
+		require.Equal(t, progress.StateInProgress, prog.Get("ok").State)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, progress.StateDone, prog.Get("ok").State)
+
+	boom := errors.New("boom")
+	err = prog.AddStep("err").Run(ctx, func(context.Context) error { return boom })
+	require.Equal(t, boom, err)
+	require.Equal(t, progress.StateFailed, prog.Get("err").State)
+	require.Equal(t, "boom", prog.Get("err").Error)
+
+	err = prog.AddStep("panic").Run(ctx, func(context.Context) error { panic("oops") })
+	require.True(t, errors.Is(err, progress.ErrStepPanicked))
+	require.Equal(t, progress.StateFailed, prog.Get("panic").State)
+	require.Contains(t, prog.Get("panic").Error, "oops")
+
+	step := prog.AddStep("manual")
+	err = step.Run(ctx, func(context.Context) error {
+		step.Done()
+		return boom
+	})
+	require.Equal(t, boom, err)
+	require.Equal(t, progress.StateDone, step.State)
+
+	// cancelled while running
+	step = prog.AddStep("cancelled")
+	err = step.Run(ctx, func(context.Context) error {
+		step.Cancel("stopped by a sibling")
+		return context.Canceled
+	})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, progress.StateStopped, step.State)
+	require.True(t, step.Cancelled)
+	require.Empty(t, step.Error)
+}
+
+func TestFocus(t *testing.T) {
+	prog := progress.New()
+	step1 := prog.AddStep("step1").SetDescription("first").Start()
+	step2 := prog.AddStep("step2").SetDescription("second")
+	prog.AddStep("step3").SetDescription("third").Start()
+	require.Equal(t, "first, third", prog.Snapshot().Doing)
+
+	step2.Focus()
+	require.Equal(t, progress.StateInProgress, step2.State)
+	require.Equal(t, progress.StateInProgress, step1.State) // not completed, unlike SetAsCurrent
+	require.Equal(t, "second", prog.Snapshot().Doing)
+
+	step1.Focus()
+	require.False(t, step2.Focused)
+	require.Equal(t, "first", prog.Snapshot().Doing)
+
+	// once the focused step is done, all the in-progress steps are displayed again
+	step1.Done()
+	require.Equal(t, "second, third", prog.Snapshot().Doing)
+	require.Panics(t, func() { step1.Focus() })
+}
+
+func TestSetChild_cycle(t *testing.T) {
+	root := progress.New()
+	child := progress.New()
+	grandchild := progress.New()
+	root.AddStep("step1").SetChild(child)
+	child.AddStep("step1").SetChild(grandchild)
+
+	// self
+	require.Equal(t, progress.ErrCyclicChild, root.AddStep("self").SafeSetChild(root))
+	// transitive
+	require.Equal(t, progress.ErrCyclicChild, grandchild.AddStep("loop").SafeSetChild(root))
+	require.Nil(t, grandchild.Get("loop").Child)
+	require.Panics(t, func() { grandchild.Get("loop").SetChild(child) })
+
+	// siblings are fine
+	require.NoError(t, grandchild.Get("loop").SafeSetChild(progress.New()))
+	require.NoError(t, root.Get("self").SafeSetChild(grandchild))
+}
+
+func TestSetChild_concurrentCycle(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		prog1 := progress.New(progress.WithSteps("step"))
+		prog2 := progress.New(progress.WithSteps("step"))
+		errs := make(chan error, 2)
+		go func() { errs <- prog1.Get("step").SafeSetChild(prog2) }()
+		go func() { errs <- prog2.Get("step").SafeSetChild(prog1) }()
+		var cyclic int
+		for j := 0; j < 2; j++ {
+			select {
+			case err := <-errs:
+				if err == progress.ErrCyclicChild {
+					cyclic++
+				}
+			case <-time.After(time.Second):
+				t.Fatal("the concurrent attachments should not deadlock")
+			}
+		}
+		require.Equal(t, 1, cyclic) // only one of them is attached
+	}
+}
+
+func TestProgress_JSON(t *testing.T) {
+	prog := progress.New(progress.WithName("build"))
+	prog.AddStep("step1")
+
+	expected, err := json.Marshal(prog)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), prog.JSON())
+	require.Contains(t, prog.JSON(), `"snapshot":{`)
+
+	pretty := prog.PrettyJSON()
+	require.Contains(t, pretty, "\n  \"name\": \"build\",\n")
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(pretty), &decoded))
+	require.Equal(t, "build", decoded["name"])
+
+	// a NaN progress rate can't be marshaled
+	prog.Get("step1").SetProgress(math.NaN())
+	for _, out := range []string{prog.JSON(), prog.PrettyJSON()} {
+		require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+		require.Contains(t, decoded["error"], "unsupported value: NaN")
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3"))
+	steps := prog.GetMany("step3", "missing", "step1")
+	require.Len(t, steps, 3)
+	require.Equal(t, "step3", steps[0].ID)
+	require.Nil(t, steps[1])
+	require.Equal(t, "step1", steps[2].ID)
+	require.Empty(t, prog.GetMany())
+}
+
+func TestWithDynamicSteps(t *testing.T) {
+	prog := progress.New(progress.WithDynamicSteps())
+	ch := prog.Subscribe()
+	prog.AddStep("step1").Start().Done()
+	require.Equal(t, progress.StateDone, prog.Snapshot().State)
+
+	// the subscriber is still open, new steps are published
+	prog.AddStep("step2").Start()
+	for i := 0; i < 4; i++ { // step1: added, started, done; step2: added
+		require.False(t, (<-ch).IsCompletion())
+	}
+	require.Equal(t, progress.StateInProgress, (<-ch).State)
+
+	// finished, but step2 is still in progress
+	prog.Finish()
+	prog.Get("step2").Done()
+	require.Equal(t, progress.StateDone, (<-ch).State)
+	require.True(t, (<-ch).IsCompletion())
+	_, ok := <-ch
+	require.False(t, ok)
+
+	// Close completes too
+	prog = progress.New(progress.WithDynamicSteps())
+	ch = prog.Subscribe()
+	prog.AddStep("step1").Done()
+	prog.Close()
+	events := 0
+	for range ch {
+		events++
+	}
+	require.Equal(t, 2, events)
+}
+
+func TestStartedFinished(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+	_, ok := step.Started()
+	require.False(t, ok)
+	_, ok = step.Finished()
+	require.False(t, ok)
+
+	step.Start()
+	startedAt, ok := step.Started()
+	require.True(t, ok)
+	require.Equal(t, *step.StartedAt, startedAt)
+	_, ok = step.Finished()
+	require.False(t, ok)
+
+	step.Done()
+	doneAt, ok := step.Finished()
+	require.True(t, ok)
+	require.False(t, doneAt.Before(startedAt))
+}
+
+func TestSnapshot_completedPerSecond(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3", "step4"))
+	require.Equal(t, 0.0, prog.Snapshot().CompletedPerSecond)
+
+	prog.Get("step1").Start()
+	for _, id := range []string{"step1", "step2"} {
+		time.Sleep(50 * time.Millisecond)
+		prog.Get(id).Done()
+	}
+	rate := prog.Snapshot().CompletedPerSecond
+	require.Greater(t, rate, 0.0)
+	require.LessOrEqual(t, rate, 20.0) // 2 steps in at least 100ms
+
+	// skipped steps count as completed
+	prog.Get("step3").Done()
+	require.Greater(t, prog.Snapshot().CompletedPerSecond, rate)
+
+	// no divide-by-zero on an instant completion
+	instant := progress.New()
+	instant.AddStep("step1").Done()
+	require.Equal(t, 0.0, instant.Snapshot().CompletedPerSecond)
+}
+
+func TestSnapshot_doingSteps(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("users").SetDescription("import table users, orders").SetProgress(0.3)
+	prog.AddStep("done").Done()
+	prog.AddStep("index").Start()
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, "import table users, orders, index", snapshot.Doing)
+	require.Equal(t, []progress.DoingEntry{
+		{ID: "users", Title: "import table users, orders", Progress: 0.3},
+		{ID: "index", Title: "index", Progress: 0.5},
+	}, snapshot.DoingSteps)
+	require.Contains(t, prog.JSON(), `"doing_steps":[{"id":"users","title":"import table users, orders","progress":0.3}`)
+
+	require.Nil(t, progress.New(progress.WithSteps("step1")).Snapshot().DoingSteps)
+}
+
+func TestSnapshotAt(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3"))
+	beforeAll := time.Now()
+	prog.Get("step1").Start()
+	time.Sleep(10 * time.Millisecond)
+	middle := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	prog.Get("step1").Done()
+	prog.Get("step2").Start()
+	afterAll := time.Now()
+
+	snapshot := prog.SnapshotAt(beforeAll)
+	require.Equal(t, progress.StateNotStarted, snapshot.State)
+	require.Equal(t, 3, snapshot.NotStarted)
+	require.Equal(t, 0.0, snapshot.Progress)
+
+	snapshot = prog.SnapshotAt(middle)
+	require.Equal(t, progress.StateInProgress, snapshot.State)
+	require.Equal(t, 1, snapshot.InProgress)
+	require.Equal(t, 0, snapshot.Completed)
+	require.Equal(t, "step1", snapshot.Doing)
+	require.Equal(t, middle.Sub(*prog.Get("step1").StartedAt), snapshot.TotalDuration)
+	require.InDelta(t, 0.5/3, snapshot.Progress, 0.0001)
+
+	snapshot = prog.SnapshotAt(afterAll)
+	require.Equal(t, 1, snapshot.Completed)
+	require.Equal(t, 1, snapshot.InProgress)
+	require.Equal(t, "step2", snapshot.Doing)
+
+	// the steps are left untouched
+	require.Equal(t, progress.StateDone, prog.Get("step1").State)
+}
+
+func TestSetProgressFunc(t *testing.T) {
+	prog := progress.New()
+	rate := 0.2
+	step := prog.AddStep("step1").SetProgressFunc(func() float64 { return rate })
+	prog.AddStep("step2")
+
+	// not evaluated until the step is in progress
+	require.Equal(t, 0.0, prog.Progress())
+	step.Start()
+	require.Equal(t, 0.1, prog.Progress())
+	rate = 0.6
+	require.Equal(t, 0.3, prog.Snapshot().Progress)
+	require.Equal(t, 0.6, prog.Snapshot().DoingSteps[0].Progress)
+
+	// clamped
+	rate = 3
+	require.Equal(t, 0.5, prog.Progress())
+
+	step.SetProgressFunc(nil)
+	require.Equal(t, 0.25, prog.Progress()) // back to the start progress
+	step.Done()
+	require.Equal(t, 0.5, prog.Progress())
+}
+
+func TestState_Code(t *testing.T) {
+	cases := map[progress.State]string{
+		progress.StateNotStarted: "not_started",
+		progress.StatePending:    "pending",
+		progress.StateInProgress: "in_progress",
+		progress.StateDone:       "done",
+		progress.StateStopped:    "stopped",
+		progress.StateFailed:     "failed",
+	}
+	for state, code := range cases {
+		require.Equal(t, code, state.Code())
+		parsed, err := progress.ParseState(code)
+		require.NoError(t, err)
+		require.Equal(t, state, parsed)
+		parsed, err = progress.ParseState(string(state))
+		require.NoError(t, err)
+		require.Equal(t, state, parsed)
+	}
+
+	_, err := progress.ParseState("in-progress")
+	require.True(t, errors.Is(err, progress.ErrUnknownState))
+	_, err = progress.ParseState("")
+	require.True(t, errors.Is(err, progress.ErrUnknownState))
+}
+
+func TestWait(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2"))
+	go func() {
+		prog.Get("step1").Done()
+		prog.Get("step2").Start().Done()
+	}()
+	snapshot, err := prog.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, progress.StateDone, snapshot.State)
+	require.Equal(t, 2, snapshot.Completed)
+
+	// already complete
+	snapshot, err = prog.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, progress.StateDone, snapshot.State)
+}
+
+func TestProgress_IsComplete(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2"))
+	require.False(t, prog.IsComplete())
+	prog.Get("step1").Done()
+	require.False(t, prog.IsComplete())
+	prog.Get("step2").Fail(errors.New("boom"))
+	require.True(t, prog.IsComplete())
+
+	// with dynamic steps, the progress should also be finished
+	prog = progress.New(progress.WithSteps("step1"), progress.WithDynamicSteps())
+	prog.Get("step1").Done()
+	require.False(t, prog.IsComplete())
+	prog.Finish()
+	require.True(t, prog.IsComplete())
+}
+
+func TestWait_noLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+	prog := progress.New(progress.WithSteps("step1"))
+	prog.Get("step1").Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	snapshot, err := prog.Wait(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Equal(t, progress.StateInProgress, snapshot.State)
+
+	requireNoGoroutineLeak(t, before)
+
+	// the subscription is released, completing the progress doesn't block
+	prog.Get("step1").Done()
+	requireNoGoroutineLeak(t, before)
+	require.Equal(t, 0, prog.DroppedEvents())
+}
+
+// requireNoGoroutineLeak waits for the number of goroutines to be back to 'expected'.
+// require.Eventually can't be used, because it runs the condition in its own goroutine.
+func requireNoGoroutineLeak(t *testing.T, expected int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > expected; {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine leak: %d goroutines, expected %d", runtime.NumGoroutine(), expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSafeGet(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1"))
+	step, err := prog.SafeGet("step1")
+	require.NoError(t, err)
+	require.Equal(t, "step1", step.ID)
+
+	step, err = prog.SafeGet("missing")
+	require.Equal(t, progress.ErrStepNotFound, err)
+	require.Nil(t, step)
+
+	step, err = prog.SafeGet("")
+	require.Equal(t, progress.ErrStepRequiresID, err)
+	require.Nil(t, step)
+}
+
+func TestSetWeightedChild(t *testing.T) {
+	prog := progress.New()
+	ch := prog.Subscribe()
+	child := progress.New(progress.WithSteps("upload", "restart"))
+	parentStep := prog.AddStep("deploy").SetWeightedChild(child, 3)
+	prog.AddStep("notify")
+	require.Equal(t, 3.0, parentStep.Weight)
+
+	// the step is started with its child, and its contribution is the child progress times its weight
+	child.Get("upload").Done()
+	require.Eventually(t, func() bool { return prog.Snapshot().InProgress == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, 0.5*3/4, prog.Progress())
+	require.Equal(t, 0.5, parentStep.Child.Progress())
+
+	// completing the child completes the step
+	child.Get("restart").Done()
+	require.Eventually(t, func() bool { return prog.Snapshot().Completed == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, progress.StateDone, parentStep.State)
+	require.Equal(t, 0.75, prog.Progress())
+
+	// the changes are published up the chain
+	prog.Get("notify").Done()
+	var last *progress.Step
+	for step := range ch {
+		last = step
+	}
+	require.True(t, last.IsCompletion())
+
+	require.Panics(t, func() { child.AddStep("loop").SetWeightedChild(prog, 2) })
+	require.Equal(t, 0.0, child.Get("loop").Weight)
+}
+
+func TestSetChild_nested(t *testing.T) {
+	root := progress.New()
+	middle := progress.New()
+	leaf := progress.New(progress.WithSteps("leaf1"))
+	root.AddStep("root1").SetChild(middle)
+	middle.AddStep("middle1").SetChild(leaf)
+
+	leaf.Get("leaf1").Start()
+	require.Eventually(t, func() bool { return root.Snapshot().State == progress.StateInProgress }, time.Second, time.Millisecond)
+	leaf.Get("leaf1").Done()
+	require.Eventually(t, func() bool { return root.Snapshot().State == progress.StateDone }, time.Second, time.Millisecond)
+}
+
+func TestSnapshot_IsTerminal(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2"))
+	require.False(t, prog.Snapshot().IsTerminal())
+	require.False(t, prog.Snapshot().IsRunning())
+
+	prog.Get("step1").Pend()
+	require.True(t, prog.Snapshot().IsRunning())
+	prog.Get("step1").Start()
+	require.True(t, prog.Snapshot().IsRunning())
+	require.False(t, prog.Snapshot().IsTerminal())
+
+	// idle, with a done step and a not started one
+	prog.Get("step1").Done()
+	require.Equal(t, progress.StateStopped, prog.Snapshot().State)
+	require.False(t, prog.Snapshot().IsTerminal())
+	require.False(t, prog.Snapshot().IsRunning())
+
+	prog.Get("step2").Start().Fail(nil)
+	require.True(t, prog.Snapshot().IsTerminal())
+	require.False(t, prog.Snapshot().IsRunning())
+
+	for _, state := range []progress.State{progress.StateDone, progress.StateStopped, progress.StateFailed} {
+		require.True(t, progress.Snapshot{State: state}.IsTerminal())
+	}
+	for _, state := range []progress.State{progress.StateNotStarted, progress.StatePending, progress.StateInProgress} {
+		require.False(t, progress.Snapshot{State: state}.IsTerminal())
+	}
+}
+
+func TestSetDeadline(t *testing.T) {
+	prog := progress.New()
+	past := time.Now().Add(-time.Minute)
+	prog.AddStep("late").SetDeadline(past).Start()
+	prog.AddStep("on-time").SetDeadline(time.Now().Add(time.Hour)).Start()
+	prog.AddStep("not-started").SetDeadline(past)
+	prog.AddStep("done").SetDeadline(past).Done()
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, 1, snapshot.Overdue)
+	require.Len(t, snapshot.DoingSteps, 2)
+	require.True(t, snapshot.DoingSteps[0].Overdue)
+	require.False(t, snapshot.DoingSteps[1].Overdue)
+	require.Equal(t, progress.StateInProgress, prog.Get("late").State)
+	require.Equal(t, past, *prog.Get("late").Deadline)
+}
+
+func TestSnapshot_unknownState(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").Done()
+	prog.AddStep("step2").State = "from the future"
+
+	require.NotPanics(t, func() {
+		snapshot := prog.Snapshot()
+		require.Equal(t, 1, snapshot.NotStarted)
+		require.Equal(t, 1, snapshot.Warnings)
+		require.Equal(t, progress.StateStopped, snapshot.State)
+		require.Equal(t, 0.5, prog.Progress())
+		require.Equal(t, time.Duration(0), prog.Get("step2").Duration())
+	})
+
+	// steps with missing timestamps, i.e., unmarshaled
+	var step progress.Step
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"step1","state":"done","started_at":"2020-01-01T00:00:00Z"}`), &step))
+	require.Equal(t, time.Duration(0), step.Duration())
+}
+
+func TestAddProgress(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+	prog.AddStep("step2")
+
+	step.AddProgress(0)
+	require.Equal(t, progress.StateNotStarted, step.State)
+	step.AddProgress(0.25)
+	require.Equal(t, progress.StateInProgress, step.State)
+	require.Equal(t, 0.25, step.Progress)
+	step.AddProgress(0.25)
+	require.Equal(t, 0.5, step.Progress)
+	step.AddProgress(-2)
+	require.Equal(t, 0.0, step.Progress) // clamped, but still in progress
+	require.Equal(t, progress.StateInProgress, step.State)
+
+	step.AddProgress(0.7).AddProgress(0.7) // clamped to 1.0, marked as done
+	require.Equal(t, progress.StateDone, step.State)
+	require.Equal(t, 0.5, prog.Progress())
+	require.Panics(t, func() { step.AddProgress(1) })
+}
+
+func TestDuration_nonNegative(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1").Start()
+	time.Sleep(time.Millisecond)
+	require.True(t, step.Duration() > 0)
+
+	// a wall clock in the future, i.e., unmarshaled from a host with a skewed clock
+	future := time.Now().Add(time.Hour).Round(0)
+	step.StartedAt = &future
+	require.Equal(t, time.Duration(0), step.Duration())
+
+	step = prog.AddStep("step2").Start().Done()
+	require.True(t, step.Duration() >= 0)
+	require.False(t, step.DoneAt.Before(*step.StartedAt))
+
+	// a clock moving backwards, without monotonic clock reading
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	prog = progress.New(progress.WithClock(func() time.Time { return now }))
+	step = prog.AddStep("step1").Start()
+	now = now.Add(time.Minute)
+	require.Equal(t, time.Minute, step.Duration())
+	now = now.Add(-time.Hour)
+	require.Equal(t, time.Duration(0), step.Duration())
+	step.Done()
+	require.Equal(t, time.Duration(0), step.Duration())
+	require.Equal(t, time.Duration(0), prog.Snapshot().TotalDuration)
+}
+
+func TestSnapshot_activeDuration(t *testing.T) {
+	at := func(seconds int) *time.Time {
+		ret := time.Date(2020, 1, 1, 0, 0, seconds, 0, time.UTC)
+		return &ret
+	}
+	setTimes := func(step *progress.Step, startedAt, doneAt int) {
+		step.State = progress.StateDone
+		step.Progress = 1
+		step.StartedAt = at(startedAt)
+		step.DoneAt = at(doneAt)
+	}
+
+	// sequential steps with an idle gap
+	prog := progress.New()
+	setTimes(prog.AddStep("step1"), 0, 1)
+	setTimes(prog.AddStep("step2"), 3, 4)
+	snapshot := prog.Snapshot()
+	require.Equal(t, 4*time.Second, snapshot.TotalDuration)
+	require.Equal(t, 2*time.Second, snapshot.ActiveDuration)
+
+	// parallel steps
+	prog = progress.New()
+	setTimes(prog.AddStep("step1"), 0, 4)
+	setTimes(prog.AddStep("step2"), 1, 3)
+	snapshot = prog.Snapshot()
+	require.Equal(t, 4*time.Second, snapshot.TotalDuration)
+	require.Equal(t, 6*time.Second, snapshot.ActiveDuration)
+
+	// not started steps do not count
+	prog = progress.New()
+	prog.AddStep("step1")
+	require.Equal(t, time.Duration(0), prog.Snapshot().ActiveDuration)
+}
+
+func TestRemoveStep(t *testing.T) {
+	prog := progress.New()
+	step1 := prog.AddStep("step1").Start()
+	step2 := prog.AddStep("step2")
+	require.True(t, step1.Attached())
+
+	ch := prog.Subscribe()
+	require.NoError(t, prog.RemoveStep("step1"))
+	require.False(t, step1.Attached())
+	event := <-ch
+	require.Equal(t, "step1", event.ID)
+	require.True(t, event.Removed)
+	prog.Unsubscribe(ch)
+	require.False(t, prog.Has("step1"))
+	require.Nil(t, prog.Get("step1"))
+	require.Equal(t, 1, prog.Len())
+	require.True(t, errors.Is(prog.RemoveStep("step1"), progress.ErrStepNotFound))
+
+	// the mutators of a detached step are no-ops
+	step1.SetProgress(0.2).SetDescription("stale").Log("stale").Done()
+	require.Equal(t, progress.StateInProgress, step1.State)
+	require.Empty(t, step1.Description)
+	require.Empty(t, step1.Logs)
+	require.True(t, errors.Is(step1.SafeSetChild(progress.New()), progress.ErrStepDetached))
+	called := false
+	err := step1.Run(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+	require.True(t, errors.Is(err, progress.ErrStepDetached))
+	require.False(t, called)
+	require.Panics(t, func() { step1.AddSubStep("sub") })
+
+	// the remaining steps are not affected
+	step2.Done()
+	require.Equal(t, progress.StateDone, prog.Snapshot().State)
+}
+
+func TestSnapshot_doingBreadcrumb(t *testing.T) {
+	prog := progress.New()
+	deploy := prog.AddStep("deploy").Start()
+	prog.AddStep("notify")
+	require.Equal(t, "deploy", prog.Snapshot().Doing)
+
+	// a child without any in-progress step
+	upload := deploy.AddSubStep("upload").SetDescription("uploading")
+	require.Equal(t, "deploy", prog.Snapshot().Doing)
+
+	upload.SetProgress(0.4)
+	require.Equal(t, "deploy > uploading (40%)", prog.Snapshot().Doing)
+
+	// deeper levels, following the focused step
+	upload.AddSubStep("part1").SetProgress(0.1)
+	upload.AddSubStep("part2").SetProgress(0.7).Focus()
+	require.Equal(t, "deploy > uploading > part2 (70%)", prog.Snapshot().Doing)
+
+	prog.Get("notify").Start()
+	require.Equal(t, "deploy > uploading > part2 (70%), notify", prog.Snapshot().Doing)
+	require.Equal(t, "deploy", prog.Snapshot().DoingSteps[0].Title)
+}
+
+func TestForceState(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+	prog.AddStep("step2")
+
+	// done -> done would panic with Done
+	step.ForceState(progress.StateDone)
+	require.Equal(t, progress.StateDone, step.State)
+	require.True(t, step.Skipped)
+	require.NotNil(t, step.DoneAt)
+	require.NotPanics(t, func() { step.ForceState(progress.StateDone) })
+
+	// done -> in progress would panic with Start
+	step.ForceState(progress.StateInProgress)
+	require.Equal(t, progress.StateInProgress, step.State)
+	require.False(t, step.Skipped)
+	require.NotNil(t, step.StartedAt)
+	require.Nil(t, step.DoneAt)
+
+	step.ForceState(progress.StateFailed)
+	require.Equal(t, progress.StateFailed, step.State)
+	require.Equal(t, progress.ErrStepFailed.Error(), step.Error)
+	require.NotNil(t, step.DoneAt)
+
+	step.ForceState(progress.StateStopped)
+	require.Equal(t, progress.StateStopped, step.State)
+
+	step.ForceState(progress.StatePending)
+	require.Equal(t, progress.StatePending, step.State)
+	require.Nil(t, step.StartedAt)
+	require.Nil(t, step.DoneAt)
+	require.Empty(t, step.Error)
+	require.Equal(t, 1, prog.Snapshot().Pending)
+
+	step.ForceState(progress.StateNotStarted)
+	require.Equal(t, progress.StateNotStarted, prog.Snapshot().State)
+}
+
+func TestSnapshot_cache(t *testing.T) {
+	prog := progress.New(progress.WithPhaseLabel("phase"))
+	prog.AddStep("step1").SetLabel("phase", "build").Start()
+	prog.AddStep("step2").SetLabel("phase", "build")
+
+	// the durations are still updated between two reads
+	first := prog.Snapshot()
+	time.Sleep(2 * time.Millisecond)
+	second := prog.Snapshot()
+	require.True(t, second.TotalDuration > first.TotalDuration)
+	require.True(t, second.ActiveDuration > first.ActiveDuration)
+	require.Equal(t, first.Doing, second.Doing)
+	require.Equal(t, first.DoingSteps, second.DoingSteps)
+
+	// the caller can't alter the cache
+	second.DoingSteps[0].Title = "altered"
+	second.Phases["build"] = progress.PhaseStats{}
+	third := prog.Snapshot()
+	require.Equal(t, "step1", third.DoingSteps[0].Title)
+	require.Equal(t, 2, third.Phases["build"].Total)
+
+	// any change invalidates the cache
+	prog.Get("step1").SetDescription("compiling")
+	require.Equal(t, "compiling", prog.Snapshot().Doing)
+	prog.Get("step2").Start()
+	require.Equal(t, 2, prog.Snapshot().InProgress)
+	require.NoError(t, prog.Reorder("step2", "step1"))
+	require.Equal(t, "step2", prog.Snapshot().DoingSteps[0].ID)
+	require.NoError(t, prog.RemoveStep("step2"))
+	require.Equal(t, 1, prog.Snapshot().Total)
+	prog.Get("step1").Done()
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateDone, snapshot.State)
+	require.Equal(t, snapshot, prog.Snapshot())
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	setup := func() *progress.Progress {
+		prog := progress.New()
+		for i := 0; i < 1000; i++ {
+			step := prog.AddStep(fmt.Sprintf("step%d", i))
+			switch i % 3 {
+			case 0:
+				step.Done()
+			case 1:
+				step.Start()
+			}
+		}
+		return prog
+	}
+	b.Run("cached", func(b *testing.B) {
+		prog := setup()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = prog.Snapshot()
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		prog := setup()
+		prog.Get("step1").SetProgressFunc(func() float64 { return 0.5 }) // disables the cache
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = prog.Snapshot()
+		}
+	})
+}
+
+func TestStep_OnStateChange(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+	prog.AddStep("step2")
+
+	type transition struct {
+		hook        int
+		old, new    progress.State
+		id          string
+		copiedState progress.State
+	}
+	transitions := make(chan transition, 100)
+	for hook := 0; hook < 2; hook++ {
+		hook := hook
+		step.OnStateChange(func(old, new progress.State, s *progress.Step) {
+			transitions <- transition{hook, old, new, s.ID, s.State}
+		})
+	}
+
+	step.SetProgress(0.2) // starts the step
+	step.SetProgress(0.3) // no transition
+	step.SetDescription("no transition")
+	step.Done()
+	step.ForceState(progress.StateFailed)
+	prog.Get("step2").Start() // another step
+
+	expected := []transition{
+		{hook: 0, old: progress.StateNotStarted, new: progress.StateInProgress},
+		{hook: 1, old: progress.StateNotStarted, new: progress.StateInProgress},
+		{hook: 0, old: progress.StateInProgress, new: progress.StateDone},
+		{hook: 1, old: progress.StateInProgress, new: progress.StateDone},
+		{hook: 0, old: progress.StateDone, new: progress.StateFailed},
+		{hook: 1, old: progress.StateDone, new: progress.StateFailed},
+	}
+	for _, want := range expected {
+		got := <-transitions
+		require.Equal(t, want.hook, got.hook)
+		require.Equal(t, want.old, got.old)
+		require.Equal(t, want.new, got.new)
+		require.Equal(t, "step1", got.id)
+		require.Equal(t, got.new, got.copiedState)
+	}
+	select {
+	case unexpected := <-transitions:
+		t.Fatalf("unexpected transition: %v", unexpected)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSetProgress_doneThreshold(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1").SetProgress(0.999999999)
+	require.Equal(t, progress.StateDone, step.State)
+	require.Equal(t, 1.0, prog.Progress())
+
+	step = prog.AddStep("step2").SetProgress(0.99999)
+	require.Equal(t, progress.StateInProgress, step.State)
+	step.SetProgress(1.0000000001)
+	require.Equal(t, progress.StateDone, step.State)
+
+	// accumulated rounding errors
+	step = prog.AddStep("step3")
+	for i := 0; i < 10; i++ {
+		step.AddProgress(0.1)
+	}
+	require.Equal(t, progress.StateDone, step.State)
+
+	// custom threshold
+	prog = progress.New(progress.WithDoneThreshold(0.99))
+	step = prog.AddStep("step1").SetProgress(0.98)
+	require.Equal(t, progress.StateInProgress, step.State)
+	step.SetProgress(0.995)
+	require.Equal(t, progress.StateDone, step.State)
+	require.Panics(t, func() { step.SetProgress(0.995) })
+
+	// 1.0 is always done
+	prog = progress.New(progress.WithDoneThreshold(2))
+	step = prog.AddStep("step1").SetProgress(1)
+	require.Equal(t, progress.StateDone, step.State)
+}
+
+func TestClose_children(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("deploy")
+	child := progress.New()
+	step.SetChild(child)
+	grandChild := progress.New()
+	child.AddStep("upload").SetChild(grandChild)
+
+	childEvents := child.Subscribe()
+	grandChildEvents := grandChild.Subscribe()
+	prog.Close()
+	for range childEvents {
+	}
+	for range grandChildEvents {
+	}
+
+	// the closed children no longer update their steps
+	child.AddStep("restart").Start()
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, progress.StateNotStarted, prog.Snapshot().State)
+}
+
+func TestRemoveStep_child(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("deploy")
+	prog.AddStep("notify")
+	child := progress.New()
+	step.SetChild(child)
+	childEvents := child.Subscribe()
+
+	require.NoError(t, prog.RemoveStep("deploy"))
+	for range childEvents {
+	}
+	child.AddStep("upload").Start()
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, progress.StateNotStarted, step.State)
+}
+
+func TestSubscribe_slowSubscriberFairness(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1")
+	slow := prog.Subscribe() // never read, fills up its buffer
+	fast := prog.Subscribe()
+
+	// one more event than the buffer of the slow subscriber: the fast one still receives it right away
+	for i := 0; i < cap(slow)+1; i++ {
+		step.SetDescription(fmt.Sprintf("update %d", i))
+	}
+	deadline := time.After(500 * time.Millisecond)
+	for i := 0; i < cap(slow)+1; i++ {
+		select {
+		case event := <-fast:
+			require.Equal(t, fmt.Sprintf("update %d", i), event.Description)
+		case <-deadline:
+			t.Fatalf("the fast subscriber only received %d events", i)
+		}
+	}
+	require.Equal(t, 0, prog.SubscriberDroppedEvents(fast))
+}
+
+func TestStartSteps_DoneSteps(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3"))
+	ch := prog.Subscribe()
+	defer prog.Unsubscribe(ch)
+
+	// all-or-nothing
+	require.True(t, errors.Is(prog.StartSteps("step1", "unknown"), progress.ErrStepNotFound))
+	require.Equal(t, 3, prog.Snapshot().NotStarted)
+
+	require.NoError(t, prog.StartSteps("step1", "step2", "step1"))
+	snapshot := prog.Snapshot()
+	require.Equal(t, 2, snapshot.InProgress)
+	require.Equal(t, "step1, step2", snapshot.Doing)
+	require.Equal(t, "step1", (<-ch).ID)
+	require.Equal(t, "step2", (<-ch).ID)
+	require.True(t, errors.Is(prog.StartSteps("step3", "step2"), progress.ErrInvalidTransition))
+	require.Equal(t, 1, prog.Snapshot().NotStarted)
+
+	require.NoError(t, prog.DoneSteps("step1", "step2"))
+	require.Equal(t, 2, prog.Snapshot().Completed)
+	require.True(t, errors.Is(prog.DoneSteps("step3", "step1"), progress.ErrInvalidTransition))
+	require.Equal(t, progress.StateNotStarted, prog.Get("step3").State)
+
+	require.NoError(t, prog.DoneSteps("step3"))
+	require.True(t, prog.Get("step3").Skipped)
+	require.Equal(t, progress.StateDone, prog.Snapshot().State)
+}
+
+func TestSnapshot_JSONContract(t *testing.T) {
+	out, err := json.Marshal(progress.New().Snapshot())
+	require.NoError(t, err)
+	require.JSONEq(t, `{"state":"not started","total":0,"progress":0}`, string(out))
+
+	prog := progress.New(progress.WithSteps("step1", "step2"))
+	out, err = json.Marshal(prog.Snapshot())
+	require.NoError(t, err)
+	require.JSONEq(t, `{"state":"not started","not_started":2,"total":2,"progress":0}`, string(out))
+}
+
+func TestStep_SetProgressBytes(t *testing.T) {
+	prog := progress.New()
+	ch := prog.Subscribe()
+	defer prog.Unsubscribe(ch)
+	step := prog.AddStep("download")
+	<-ch
+
+	step.SetProgressBytes(1000, 4000)
+	event := <-ch
+	require.Equal(t, progress.StateInProgress, event.State)
+	require.Equal(t, 0.25, event.Progress)
+	require.Equal(t, int64(1000), event.Bytes)
+	require.Equal(t, int64(4000), event.TotalBytes)
+	require.Equal(t, 0.0, event.Rate) // no prior sample
+
+	time.Sleep(10 * time.Millisecond)
+	step.SetProgressBytes(3000, 4000)
+	event = <-ch
+	require.Equal(t, 0.75, event.Progress)
+	require.True(t, event.Rate > 0 && event.Rate <= 2000/0.01, event.Rate)
+	require.Equal(t, 0.0, step.Rate) // only on the published copies
+
+	step.SetProgressBytes(4000, 4000)
+	event = <-ch
+	require.Equal(t, progress.StateDone, event.State)
+	require.True(t, event.Rate > 0)
+}
+
+func TestBindErrGroup(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3", "step4"))
+	prog.Get("step1").Done()
+	prog.Get("step2").Start()
+	prog.Get("step3").Pend()
+	ctx, cancel := context.WithCancel(context.Background())
+	prog.BindErrGroup(ctx)
+
+	cancel()
+	snapshot, err := prog.Wait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, progress.StateStopped, snapshot.State)
+	require.Equal(t, 1, snapshot.Completed)
+	require.Equal(t, 3, snapshot.Cancelled)
+	require.Equal(t, context.Canceled.Error(), prog.Get("step2").Reason)
+	require.True(t, prog.Get("step4").Cancelled)
+
+	// the binding stops with the progress
+	before := runtime.NumGoroutine()
+	prog = progress.New(progress.WithSteps("step1"))
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	prog.BindErrGroup(ctx)
+	prog.Get("step1").Done()
+	requireNoGoroutineLeak(t, before)
+	require.Equal(t, progress.StateDone, prog.Snapshot().State)
+}
+
+func TestBindErrGroup_run(t *testing.T) {
+	// like an errgroup.Group: the first error cancels the context of the group
+	prog := progress.New(progress.WithSteps("build", "test"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prog.BindErrGroup(ctx)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		err := prog.Get("build").Run(ctx, func(context.Context) error {
+			// fails while its sibling is running
+			require.Eventually(t, func() bool { return prog.Snapshot().InProgress == 2 }, time.Second, time.Millisecond)
+			return errors.New("boom")
+		})
+		if err != nil {
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		_ = prog.Get("test").RunWithRetry(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			require.Eventually(t, func() bool { return prog.Snapshot().Cancelled == 1 }, time.Second, time.Millisecond)
+			return ctx.Err()
+		})
+	}()
+	wg.Wait()
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, 1, snapshot.Failed)
+	require.Equal(t, 1, snapshot.Cancelled)
+	require.Equal(t, progress.StateStopped, prog.Get("test").State)
+	require.Empty(t, prog.Get("test").Error)
+
+	// a stopped step that is failed afterwards is not cancelled anymore
+	step := progress.New().AddStep("step1").Start().Cancel("user")
+	step.Fail(errors.New("boom"))
+	require.False(t, step.Cancelled)
+	require.Empty(t, step.Reason)
+}
+
+func TestStep_PauseResume(t *testing.T) {
+	prog := progress.New()
+	step := prog.AddStep("step1")
+
+	// not in progress
+	step.Pause()
+	require.False(t, step.Paused)
+
+	step.Start()
+	time.Sleep(10 * time.Millisecond)
+	step.Pause()
+	require.True(t, step.Paused)
+	require.Equal(t, progress.StateInProgress, step.State)
+	paused := step.Duration()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, paused, step.Duration())
+	step.Resume()
+	require.False(t, step.Paused)
+	require.True(t, step.PausedDuration >= 50*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	step.Done()
+	require.True(t, step.Duration() >= 20*time.Millisecond)
+	require.True(t, step.Duration() < step.DoneAt.Sub(*step.StartedAt)-40*time.Millisecond)
+
+	// done while paused
+	step = prog.AddStep("step2").Start().Pause()
+	time.Sleep(20 * time.Millisecond)
+	step.Done()
+	require.False(t, step.Paused)
+	require.True(t, step.PausedDuration >= 20*time.Millisecond)
+	require.True(t, step.Duration() < 20*time.Millisecond)
+}
+
+func TestStep_Clone(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1").SetDescription("before").Start()
+	step.AddWarning("warning1")
+	child := progress.New()
+	child.AddStep("child1").Start()
+	step.SetChild(child)
+
+	clone := step.Clone()
+	require.Equal(t, "step1", clone.ID)
+	require.Equal(t, "before", clone.Description)
+	require.Equal(t, progress.StateInProgress, clone.State)
+	require.NotNil(t, clone.Child)
+	require.NotSame(t, child, clone.Child)
+
+	step.SetDescription("after")
+	step.AddWarning("warning2")
+	child.Get("child1").Done()
+	require.Equal(t, "before", clone.Description)
+	require.Equal(t, []string{"warning1"}, clone.Warnings)
+	require.Equal(t, progress.StateInProgress, clone.Child.Get("child1").State)
+	require.Equal(t, progress.StateInProgress, clone.Child.Snapshot().State)
+	require.Equal(t, progress.StateDone, child.Get("child1").State)
+}
+
+func TestProgress_GetOrAddStep(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.GetOrAddStep("step1").SetDescription("hello")
+	require.Same(t, step, prog.GetOrAddStep("step1"))
+	require.Equal(t, "hello", prog.GetOrAddStep("step1").Description)
+	require.Equal(t, 1, prog.Len())
+	require.Panics(t, func() { prog.GetOrAddStep("") })
+
+	// concurrent callers get the same step
+	var (
+		wg    sync.WaitGroup
+		steps = make([]*progress.Step, 10)
+	)
+	for i := range steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			steps[i] = prog.GetOrAddStep("step2")
+		}(i)
+	}
+	wg.Wait()
+	for _, step := range steps {
+		require.Same(t, steps[0], step)
+	}
+	require.Equal(t, 2, prog.Len())
+}
+
+func TestStep_TimeInState(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1")
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, step.TimeIn(progress.StateNotStarted) >= 20*time.Millisecond)
+	require.Empty(t, step.TimeInState)
+
+	step.Start()
+	notStarted := step.TimeInState[progress.StateNotStarted]
+	require.True(t, notStarted >= 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	step.Done()
+	require.Equal(t, notStarted, step.TimeInState[progress.StateNotStarted])
+	require.True(t, step.TimeInState[progress.StateInProgress] >= 30*time.Millisecond)
+	require.Equal(t, time.Duration(0), step.TimeInState[progress.StatePending])
+
+	// the terminal states are not tracked
+	done := step.TimeIn(progress.StateDone)
+	require.Equal(t, time.Duration(0), done)
+	require.Equal(t, step.TimeInState[progress.StateInProgress], step.TimeIn(progress.StateInProgress))
+}
+
+func TestStep_RunWithRetry(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1").WithRetryBackoff(3, 20*time.Millisecond)
+
+	calls := 0
+	errBoom := errors.New("boom")
+	err := step.RunWithRetry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errBoom
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	require.Equal(t, 3, step.Attempts)
+	require.Equal(t, progress.StateDone, step.State)
+	require.Nil(t, step.NextRetryAt)
+
+	// exhausted
+	step = prog.AddStep("step2").WithRetryBackoff(2, time.Millisecond)
+	calls = 0
+	err = step.RunWithRetry(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errBoom
+	})
+	require.True(t, errors.Is(err, errBoom))
+	require.Equal(t, 2, calls)
+	require.Equal(t, progress.StateFailed, step.State)
+	require.Equal(t, "boom", step.Error)
+}
+
+func TestStep_RunWithRetry_backoff(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1").WithRetryBackoff(3, 40*time.Millisecond)
+
+	started := time.Now()
+	calls := []time.Duration{}
+	_ = step.RunWithRetry(context.Background(), func(ctx context.Context) error {
+		calls = append(calls, time.Since(started))
+		return errors.New("boom")
+	})
+	require.Len(t, calls, 3)
+	require.True(t, calls[1]-calls[0] >= 40*time.Millisecond)
+	require.True(t, calls[2]-calls[1] >= 80*time.Millisecond)
+}
+
+func TestStep_RunWithRetry_nextRetryAt(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1").WithRetryBackoff(2, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- step.RunWithRetry(ctx, func(ctx context.Context) error {
+			return errors.New("boom")
+		})
+	}()
+	require.Eventually(t, func() bool { return prog.Snapshot().NextRetryAt != nil }, time.Second, time.Millisecond)
+	require.Equal(t, progress.StatePending, step.State)
+	require.Equal(t, "boom", step.Error)
+	require.True(t, time.Until(*prog.Snapshot().NextRetryAt) > 59*time.Minute)
+
+	// canceled while waiting
+	cancel()
+	require.True(t, errors.Is(<-done, context.Canceled))
+	require.Equal(t, progress.StateStopped, step.State)
+	require.True(t, step.Cancelled)
+	require.Nil(t, prog.Snapshot().NextRetryAt)
+}
+
+func TestWithClock_outOfOrderTimestamps(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var now time.Time
+	at := func(seconds int) { now = base.Add(time.Duration(seconds) * time.Second) }
+	at(0)
+	prog := progress.New(progress.WithClock(func() time.Time { return now }))
+	defer prog.Close()
+	require.Equal(t, base, prog.CreatedAt)
+
+	// the clock goes backward between the start and the end of each step
+	step1 := prog.AddStep("step1")
+	step2 := prog.AddStep("step2")
+	at(30)
+	step1.Start()
+	at(40)
+	step2.Start()
+	require.Equal(t, 10*time.Second, step1.Duration())
+	require.Equal(t, 10*time.Second, prog.Snapshot().TotalDuration)
+	at(5)
+	step1.Done()
+	at(1)
+	step2.Done()
+
+	require.Equal(t, base.Add(30*time.Second), *step1.StartedAt)
+	require.Equal(t, base.Add(5*time.Second), *step1.DoneAt)
+	require.Equal(t, time.Duration(0), step1.Duration())
+	require.Equal(t, time.Duration(0), step2.Duration())
+	snapshot := prog.Snapshot()
+	require.Equal(t, progress.StateDone, snapshot.State)
+	require.Equal(t, time.Duration(0), snapshot.TotalDuration)
+	require.Equal(t, time.Duration(0), snapshot.ActiveDuration)
+}
+
+func TestStepMarshalJSON_percent(t *testing.T) {
+	percent := func(step *progress.Step) interface{} {
+		out, err := json.Marshal(step)
+		require.NoError(t, err)
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(out, &decoded))
+		return decoded["percent"]
+	}
+
+	prog := progress.New(progress.WithPercentMode(progress.PercentRound))
+	defer prog.Close()
+	step := prog.AddStep("step1")
+	require.Equal(t, float64(0), percent(step))
+	step.SetProgress(0.666)
+	require.Equal(t, float64(67), percent(step))
+	require.Equal(t, 67, prog.Percent())
+	step.Done()
+	require.Equal(t, float64(100), percent(step))
+
+	prog = progress.New()
+	defer prog.Close()
+	step = prog.AddStep("step1").SetProgress(0.666)
+	require.Equal(t, float64(66), percent(step))
+	clone := step.Clone()
+	require.Equal(t, float64(66), percent(&clone))
+
+	// driven by a child progress
+	child := progress.New(progress.WithSteps("a", "b", "c", "d"))
+	defer child.Close()
+	step = prog.AddStep("step2").Start().SetChild(child)
+	child.Get("a").Done()
+	child.Get("b").Start()
+	require.Equal(t, "37%", step.PercentString())
+	require.Equal(t, float64(37), percent(step))
+}
+
+func TestProgress_SetSteps(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3"), progress.WithSnapshotStepIDs())
+	defer prog.Close()
+	step1 := prog.Get("step1").Start()
+	step3 := prog.Get("step3")
+	step2 := prog.Get("step2")
+
+	ch := prog.Subscribe()
+	require.NoError(t, prog.SetSteps([]string{"step3", "step4", "step1"}))
+	ids := []string{}
+	for _, step := range prog.Steps {
+		ids = append(ids, step.ID)
+	}
+	require.Equal(t, []string{"step3", "step4", "step1"}, ids)
+	require.Same(t, step1, prog.Get("step1"))
+	require.Same(t, step3, prog.Get("step3"))
+	require.Equal(t, progress.StateInProgress, step1.State)
+	require.Nil(t, prog.Get("step2"))
+	require.False(t, step2.Attached())
+	require.Equal(t, progress.StateNotStarted, prog.Get("step4").State)
+
+	event := <-ch
+	require.Equal(t, "step2", event.ID)
+	require.True(t, event.Removed)
+	event = <-ch
+	require.Equal(t, "step4", event.ID)
+	require.False(t, event.Removed)
+
+	// the snapshot subscribers only receive the final state
+	snapshots := prog.SubscribeSnapshots()
+	require.NoError(t, prog.SetSteps([]string{"step3", "step5", "step6", "step1"}))
+	snapshot := <-snapshots
+	require.Equal(t, 4, snapshot.Total)
+	require.Equal(t, []string{"step3", "step5", "step6", "step1"}, snapshot.RemainingSteps)
+	select {
+	case snapshot := <-snapshots:
+		t.Fatalf("unexpected intermediate snapshot: %v", snapshot)
+	case <-time.After(20 * time.Millisecond):
+	}
+	require.NoError(t, prog.SetSteps([]string{"step3", "step4", "step1"}))
+	require.Equal(t, 3, (<-snapshots).Total)
+
+	// a reordering only publishes a snapshot
+	require.NoError(t, prog.SetSteps([]string{"step1", "step3", "step4"}))
+	require.Equal(t, []string{"step1", "step3", "step4"}, (<-snapshots).RemainingSteps)
+	require.NoError(t, prog.SetSteps([]string{"step1", "step3", "step4"})) // unchanged
+	select {
+	case <-snapshots:
+		t.Fatal("an unchanged step list should not publish a snapshot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// invalid ids leave the progress untouched
+	require.Equal(t, progress.ErrStepRequiresID, prog.SetSteps([]string{"step1", ""}))
+	require.Equal(t, progress.ErrStepIDShouldBeUnique, prog.SetSteps([]string{"step1", "step1"}))
+	require.Equal(t, 3, prog.Len())
+}
+
+func TestSnapshot_stepIDs(t *testing.T) {
+	prog := progress.New(progress.WithSteps("step1", "step2", "step3", "step4", "step5"), progress.WithSnapshotStepIDs())
+	defer prog.Close()
+	prog.Get("step1").Start().Done()
+	prog.Get("step2").Start()
+	prog.Get("step4").Start()
+	prog.Get("step5").Start().Fail(errors.New("boom"))
+
+	snapshot := prog.Snapshot()
+	require.Equal(t, []string{"step2", "step3", "step4", "step5"}, snapshot.RemainingSteps)
+	require.Equal(t, []string{"step2", "step4"}, snapshot.InProgressSteps)
+	require.Len(t, snapshot.RemainingSteps, prog.RemainingCount())
+
+	// the cached snapshot is copied
+	snapshot.RemainingSteps[0] = "altered"
+	require.Equal(t, []string{"step2", "step3", "step4", "step5"}, prog.Snapshot().RemainingSteps)
+
+	// opt-in
+	prog = progress.New(progress.WithSteps("step1"))
+	defer prog.Close()
+	prog.Get("step1").Start()
+	require.Nil(t, prog.Snapshot().RemainingSteps)
+	require.Nil(t, prog.Snapshot().InProgressSteps)
+}
+
+func TestSnapshot_cacheExportedFields(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1")
+	require.Equal(t, 0, prog.Snapshot().Warnings)
+
+	// written directly, without a method call
+	step.State = "from the future"
+	require.Equal(t, 1, prog.Snapshot().Warnings)
+	step.State = progress.StateInProgress
+	step.Progress = 0.5
+	require.Equal(t, 0.5, prog.Snapshot().Progress)
+	step.Name = "renamed"
+	require.Equal(t, "renamed", prog.Snapshot().Doing)
+}
+
+func TestProgress_Get_unindexedSteps(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	prog.AddStep("a").Done()
+	prog.AddStep("b")
+
+	var decoded progress.Progress
+	require.NoError(t, json.Unmarshal([]byte(prog.JSON()), &decoded))
+	require.NotNil(t, decoded.Get("a"))
+	require.Equal(t, progress.StateDone, decoded.Get("a").State)
+	require.True(t, decoded.Has("b"))
+	require.Equal(t, []*progress.Step{decoded.Steps[1], nil}, decoded.GetMany("b", "c"))
+	step, err := decoded.SafeGet("b")
+	require.NoError(t, err)
+	require.Same(t, decoded.Steps[1], step)
+
+	// appended to the exported Steps
+	prog.Steps = append(prog.Steps, &progress.Step{ID: "c", State: progress.StateNotStarted})
+	require.NotNil(t, prog.Get("c"))
+	require.Equal(t, progress.ErrStepIDShouldBeUnique, func() error { _, err := prog.SafeAddStep("c"); return err }())
+	require.NoError(t, prog.Reorder("c", "a", "b"))
+
+	// removed from the exported Steps, then replaced by another step: same length, but a stale index
+	removed := prog.Get("a")
+	prog.Steps = append(prog.Steps[:1], prog.Steps[2:]...)
+	prog.Steps = append(prog.Steps, &progress.Step{ID: "d", State: progress.StateNotStarted})
+	require.Nil(t, prog.Get("a"))
+	require.False(t, prog.Has("a"))
+	require.Same(t, prog.Steps[1], prog.Get("b"))
+	require.NotSame(t, removed, prog.Get("b"))
+	require.Equal(t, progress.ErrStepNotFound, prog.RemoveStep("a"))
+
+	// renamed in place
+	prog.Steps[1].ID = "e"
+	require.Nil(t, prog.Get("b"))
+}
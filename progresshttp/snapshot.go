@@ -0,0 +1,43 @@
+package progresshttp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"moul.io/progress"
+)
+
+// SnapshotHandler returns an HTTP handler responding to GET requests with the JSON representation of the
+// progress (see progress.Progress.MarshalJSON), for polling clients.
+// With the "?fields=snapshot" query, only the current snapshot is returned.
+func SnapshotHandler(p *progress.Progress) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var (
+			out []byte
+			err error
+		)
+		switch r.URL.Query().Get("fields") {
+		case "":
+			out, err = json.Marshal(p)
+		case "snapshot":
+			out, err = json.Marshal(p.Snapshot())
+		default:
+			http.Error(w, "unsupported fields, expected \"snapshot\"", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=1")
+		_, _ = w.Write(out)
+	}
+}
@@ -0,0 +1,77 @@
+package progresshttp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+	"moul.io/progress/progresshttp"
+)
+
+func TestSnapshotHandler(t *testing.T) {
+	prog := progress.New(progress.WithName("build"))
+	prog.AddStep("step1").Done()
+	prog.AddStep("step2")
+	handler := progresshttp.SnapshotHandler(prog)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.Equal(t, "max-age=1", rec.Header().Get("Cache-Control"))
+	var full struct {
+		Name     string            `json:"name"`
+		Steps    []progress.Step   `json:"steps"`
+		Snapshot progress.Snapshot `json:"snapshot"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &full))
+	require.Equal(t, "build", full.Name)
+	require.Len(t, full.Steps, 2)
+	require.Equal(t, 1, full.Snapshot.Completed)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/?fields=snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var snapshot progress.Snapshot
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+	require.Equal(t, 2, snapshot.Total)
+	require.NotContains(t, rec.Body.String(), `"steps"`)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/?fields=steps", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestSnapshotHandler_concurrentChanges(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	step := prog.AddStep("step1").Start()
+	child := progress.New()
+	child.AddStep("child1").Start()
+	step.SetChild(child)
+	handler := progresshttp.SnapshotHandler(prog)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			step.SetDescription(fmt.Sprintf("update %d", i))
+			child.Get("child1").SetProgress(float64(i) / 200)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		_ = prog.JSON()
+	}
+	<-done
+}
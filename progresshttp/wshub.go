@@ -0,0 +1,265 @@
+// Package progresshttp serves the snapshots of a progress.Progress over HTTP.
+package progresshttp
+
+import (
+	"bufio"
+	"crypto/sha1" // nolint:gosec // required by the WebSocket handshake
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"moul.io/progress"
+)
+
+// WSHub broadcasts the snapshots of a progress.Progress to WebSocket clients.
+// It uses a single subscription on the progress, whatever the number of connected clients.
+//
+// It implements a minimal server-side, send-only subset of the WebSocket protocol (RFC 6455):
+// messages sent by the clients are ignored, except for the close frames.
+type WSHub struct {
+	prog    *progress.Progress
+	mutex   sync.Mutex
+	clients map[*wsClient]struct{}
+	closed  bool
+	done    chan struct{}
+}
+
+type wsClient struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	send chan []byte
+	quit chan struct{}
+	once sync.Once
+}
+
+const (
+	wsGUID             = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsOpText           = 0x1
+	wsOpClose          = 0x8
+	wsFinBit           = 0x80
+	wsMaskBit          = 0x80
+	wsClientSendLength = 16
+)
+
+// NewWSHub creates a WSHub that starts broadcasting the snapshots of the progress.
+// The hub is stopped and its clients are disconnected when the progress subscribers are closed, or right
+// away if the progress is already complete.
+func NewWSHub(p *progress.Progress) *WSHub {
+	hub := &WSHub{
+		prog:    p,
+		clients: make(map[*wsClient]struct{}),
+		done:    make(chan struct{}),
+	}
+	ch := p.Subscribe()
+	// the progress may already be complete, in this case no event will be published anymore
+	if p.IsComplete() {
+		p.Unsubscribe(ch)
+	}
+	go hub.run(ch)
+	return hub
+}
+
+// Done returns a chan that is closed when the hub is stopped.
+func (h *WSHub) Done() <-chan struct{} {
+	return h.done
+}
+
+func (h *WSHub) run(ch chan *progress.Step) {
+	for step := range ch {
+		var snapshot progress.Snapshot
+		if step != nil && step.IsCompletion() {
+			snapshot = *step.Snapshot
+		} else {
+			snapshot = h.prog.Snapshot()
+		}
+		msg, err := json.Marshal(snapshot)
+		if err != nil {
+			continue
+		}
+		h.broadcast(msg)
+	}
+
+	h.mutex.Lock()
+	h.closed = true
+	for client := range h.clients {
+		client.stop()
+		delete(h.clients, client)
+	}
+	h.mutex.Unlock()
+	close(h.done)
+}
+
+func (h *WSHub) broadcast(msg []byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for client := range h.clients {
+		select {
+		case client.send <- msg:
+		default: // the client is too slow, drop this snapshot; the next one will catch up
+		}
+	}
+}
+
+// ServeHTTP upgrades the connection to a WebSocket, sends the current snapshot, then the next ones
+// until the client disconnects or the hub is stopped.
+func (h *WSHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		r.Header.Get("Sec-WebSocket-Key") == "" {
+		http.Error(w, "expected a WebSocket upgrade request", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported by this server", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID)) // nolint:gosec
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	client := &wsClient{
+		conn: conn,
+		rw:   rw,
+		send: make(chan []byte, wsClientSendLength),
+		quit: make(chan struct{}),
+	}
+
+	// the initial snapshot is queued before registering, so it's always the first message
+	if msg, err := json.Marshal(h.prog.Snapshot()); err == nil {
+		client.send <- msg
+	}
+	h.mutex.Lock()
+	if h.closed {
+		client.stop()
+	} else {
+		h.clients[client] = struct{}{}
+	}
+	h.mutex.Unlock()
+
+	go client.readLoop()
+	client.writeLoop()
+
+	h.mutex.Lock()
+	delete(h.clients, client)
+	h.mutex.Unlock()
+}
+
+// writeLoop sends the queued messages until the client is stopped, then closes the connection.
+func (c *wsClient) writeLoop() {
+	defer c.conn.Close()
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.writeFrame(wsOpText, msg); err != nil {
+				return
+			}
+		case <-c.quit:
+			// flush the pending messages, then say goodbye
+			for {
+				select {
+				case msg := <-c.send:
+					if err := c.writeFrame(wsOpText, msg); err != nil {
+						return
+					}
+				default:
+					_ = c.writeFrame(wsOpClose, []byte{0x03, 0xe8}) // 1000: normal closure
+					return
+				}
+			}
+		}
+	}
+}
+
+// readLoop discards the client messages and stops the client on close or error.
+func (c *wsClient) readLoop() {
+	defer c.stop()
+	for {
+		opcode, err := c.discardFrame()
+		if err != nil || opcode == wsOpClose {
+			return
+		}
+	}
+}
+
+func (c *wsClient) stop() {
+	c.once.Do(func() { close(c.quit) })
+}
+
+func (c *wsClient) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{wsFinBit | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsClient) discardFrame() (byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return 0, err
+	}
+	length := uint64(header[1] &^ wsMaskBit)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if header[1]&wsMaskBit != 0 {
+		length += 4 // masking key
+	}
+	if _, err := io.CopyN(io.Discard, c.rw, int64(length)); err != nil {
+		return 0, err
+	}
+	return header[0] & 0x0f, nil
+}
+
+func headerContains(header http.Header, key, value string) bool {
+	for _, field := range strings.Split(header.Get(key), ",") {
+		if strings.EqualFold(strings.TrimSpace(field), value) {
+			return true
+		}
+	}
+	return false
+}
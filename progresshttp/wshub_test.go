@@ -0,0 +1,150 @@
+package progresshttp_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+	"moul.io/progress/progresshttp"
+)
+
+type wsTestClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialWS(t *testing.T, server *httptest.Server) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	return &wsTestClient{conn: conn, reader: reader}
+}
+
+// readFrame returns the opcode and payload of the next frame sent by the server.
+func (c *wsTestClient) readFrame(t *testing.T) (byte, []byte) {
+	t.Helper()
+	require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var header [2]byte
+	_, err := io.ReadFull(c.reader, header[:])
+	require.NoError(t, err)
+	length := int(header[1])
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err := io.ReadFull(c.reader, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err := io.ReadFull(c.reader, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	require.NoError(t, err)
+	return header[0] & 0x0f, payload
+}
+
+func (c *wsTestClient) readSnapshot(t *testing.T) progress.Snapshot {
+	t.Helper()
+	opcode, payload := c.readFrame(t)
+	require.Equal(t, byte(0x1), opcode)
+	var snapshot progress.Snapshot
+	require.NoError(t, json.Unmarshal(payload, &snapshot))
+	return snapshot
+}
+
+func TestWSHub(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	hub := progresshttp.NewWSHub(prog)
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	// each client receives the current snapshot on connect
+	client1 := dialWS(t, server)
+	defer client1.conn.Close()
+	require.Equal(t, 2, client1.readSnapshot(t).Total)
+	client2 := dialWS(t, server)
+	defer client2.conn.Close()
+	require.Equal(t, 2, client2.readSnapshot(t).Total)
+
+	// updates are broadcast to all the clients
+	prog.Get("step1").Start()
+	require.Equal(t, progress.StateInProgress, client1.readSnapshot(t).State)
+	require.Equal(t, progress.StateInProgress, client2.readSnapshot(t).State)
+
+	// a disconnected client doesn't prevent the others from receiving
+	client2.conn.Close()
+	prog.Get("step1").Done()
+	require.Equal(t, 1, client1.readSnapshot(t).Completed)
+
+	// the hub is stopped when the progress is done
+	prog.Get("step2").Done()
+	for {
+		opcode, _ := client1.readFrame(t)
+		if opcode == 0x8 { // close
+			break
+		}
+	}
+	select {
+	case <-hub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("hub was not stopped")
+	}
+
+	// new clients receive the final snapshot, then are disconnected
+	client3 := dialWS(t, server)
+	defer client3.conn.Close()
+	require.Equal(t, progress.StateDone, client3.readSnapshot(t).State)
+	opcode, _ := client3.readFrame(t)
+	require.Equal(t, byte(0x8), opcode)
+}
+
+func TestWSHub_alreadyComplete(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	prog.AddStep("step1").Done()
+	hub := progresshttp.NewWSHub(prog)
+	select {
+	case <-hub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("hub was not stopped")
+	}
+}
+
+func TestWSHub_badRequest(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	server := httptest.NewServer(progresshttp.NewWSHub(prog))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,269 @@
+package progress
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// publication is a queued event: a step sent to some subscribers, and/or subscribers to close.
+// A terminal publication (a transition to a terminal state, or the completion) is never dropped, see deliver.
+// The hooks, if any, are the Step.OnStateChange callbacks to call once the step is delivered.
+// The notify func, if any, is called last, i.e., to update the owner of a child progress.
+// The snapshot, if any, is sent to the snapshot subscribers, see SubscribeSnapshots.
+type publication struct {
+	step            *Step
+	source          *Step // the published step, to coalesce its queued events, see enqueue
+	targets         []*subscription
+	terminal        bool
+	close           []*subscription
+	hooks           []func()
+	notify          func()
+	snapshot        *Snapshot
+	snapshotTargets []chan Snapshot
+	closeSnapshots  []chan Snapshot
+}
+
+// enqueue appends a publication to the queue and starts the dispatcher if needed.
+// It should be called while holding the lock, so the queue follows the order of the changes.
+// While the dispatcher waits for a slow subscriber, a droppable event replaces the previous queued event of
+// the same step, or the previous queued snapshot, if it's droppable too; so the queue doesn't grow with
+// the rapid updates of a step, and holds at most one droppable event per step, plus the terminal events.
+func (p *Progress) enqueue(pub publication) {
+	p.publishMutex.Lock()
+	defer p.publishMutex.Unlock()
+	if p.dispatchWaiting && pub.droppable() {
+		for idx := len(p.publishQueue) - 1; idx >= 0; idx-- {
+			queued := p.publishQueue[idx]
+			if len(queued.close) > 0 || len(queued.closeSnapshots) > 0 { // the targets changed
+				break
+			}
+			if queued.source != pub.source || (queued.snapshot == nil) != (pub.snapshot == nil) {
+				continue
+			}
+			if queued.droppable() {
+				p.publishQueue[idx] = pub
+				return
+			}
+			break
+		}
+	}
+	p.publishQueue = append(p.publishQueue, pub)
+	if !p.publishing {
+		p.publishing = true
+		go p.dispatch()
+	}
+}
+
+// droppable returns true if the publication is only a non-terminal step event or only a snapshot, which a
+// newer one can replace, see enqueue.
+func (pub publication) droppable() bool {
+	if pub.terminal || len(pub.close) > 0 || len(pub.closeSnapshots) > 0 || len(pub.hooks) > 0 {
+		return false
+	}
+	if pub.snapshot != nil {
+		return pub.step == nil && pub.source == nil
+	}
+	return pub.source != nil
+}
+
+// dispatch delivers the queued publications, in order, without holding the main lock, so a slow
+// subscriber only delays the other subscribers, never the callers of the Progress methods.
+// It returns as soon as the queue is empty; the next enqueue starts a new one.
+func (p *Progress) dispatch() {
+	for {
+		p.publishMutex.Lock()
+		if len(p.publishQueue) == 0 {
+			p.publishing = false
+			p.publishQueue = nil
+			p.publishMutex.Unlock()
+			return
+		}
+		pub := p.publishQueue[0]
+		p.publishQueue[0] = publication{}
+		p.publishQueue = p.publishQueue[1:]
+		p.publishMutex.Unlock()
+
+		p.deliver(pub)
+		if pub.snapshot != nil {
+			p.deliverSnapshot(pub)
+		}
+		for _, sub := range pub.close {
+			p.closeSubscription(sub)
+		}
+		for _, ch := range pub.closeSnapshots {
+			close(ch)
+		}
+		for _, hook := range pub.hooks {
+			hook()
+		}
+		if pub.notify != nil {
+			pub.notify()
+		}
+	}
+}
+
+// deliver sends the step of a publication to its targets, it should only be called by the dispatcher.
+// The ready subscribers are served first, then each slow subscriber is given publishTimeout to receive the
+// step before it is dropped for it. A subscriber that timed out is stalled: its next events are dropped
+// without waiting, until its chan is drained. The first target is rotated on each publication, so no
+// subscriber is always served last.
+// The terminal events are never dropped: those a subscriber has no room for are queued for it and sent
+// by another goroutine, see flushPending; until they are sent, its non-terminal events are dropped, to keep
+// the order.
+func (p *Progress) deliver(pub publication) {
+	if len(pub.targets) == 0 {
+		return
+	}
+	p.dispatchOffset = (p.dispatchOffset + 1) % len(pub.targets)
+	slow := make([]*subscription, 0, len(pub.targets))
+	for i := range pub.targets {
+		sub := pub.targets[(p.dispatchOffset+i)%len(pub.targets)]
+		sub.pendingMutex.Lock()
+		if sub.stalled && len(sub.ch) == 0 {
+			sub.stalled = false
+		}
+		switch {
+		case len(sub.pending) > 0 && pub.terminal:
+			sub.pending = append(sub.pending, pub.step)
+		case len(sub.pending) > 0:
+			p.drop(sub)
+		default:
+			select {
+			case sub.ch <- pub.step:
+			default:
+				switch {
+				case pub.terminal:
+					sub.pending = append(sub.pending, pub.step)
+				case sub.stalled:
+					p.drop(sub)
+				default:
+					slow = append(slow, sub)
+				}
+			}
+		}
+		if len(sub.pending) > 0 && !sub.flushing {
+			sub.flushing = true
+			go p.flushPending(sub)
+		}
+		sub.pendingMutex.Unlock()
+	}
+	if len(slow) == 0 {
+		return
+	}
+	p.setDispatchWaiting(true)
+	defer p.setDispatchWaiting(false)
+	for _, sub := range slow {
+		select {
+		case sub.ch <- pub.step:
+		case <-time.After(publishTimeout):
+			p.drop(sub)
+			sub.pendingMutex.Lock()
+			sub.stalled = true
+			sub.pendingMutex.Unlock()
+		}
+	}
+}
+
+// setDispatchWaiting records whether the dispatcher waits for a slow subscriber; when it starts waiting, the
+// events that were queued meanwhile are coalesced, like the next ones, see enqueue.
+func (p *Progress) setDispatchWaiting(waiting bool) {
+	p.publishMutex.Lock()
+	defer p.publishMutex.Unlock()
+	p.dispatchWaiting = waiting
+	if waiting {
+		p.coalesceQueue()
+	}
+}
+
+// coalesceQueue removes the droppable publications superseded by a later droppable one of the same step (or
+// a later snapshot), it should be called while holding publishMutex.
+func (p *Progress) coalesceQueue() {
+	type key struct {
+		source   *Step
+		snapshot bool
+	}
+	const (
+		superseded = iota + 1 // a later droppable publication replaces the previous ones
+		blocked               // a later publication of the same step can't be dropped, the previous ones are kept
+	)
+	later := make(map[key]int)
+	skip := make([]bool, len(p.publishQueue))
+	for idx := len(p.publishQueue) - 1; idx >= 0; idx-- {
+		pub := p.publishQueue[idx]
+		k := key{source: pub.source, snapshot: pub.snapshot != nil}
+		switch {
+		case len(pub.close) > 0 || len(pub.closeSnapshots) > 0: // the targets changed
+			later = make(map[key]int)
+		case !pub.droppable():
+			later[k] = blocked
+		case later[k] == superseded:
+			skip[idx] = true
+		default:
+			later[k] = superseded
+		}
+	}
+	queue := make([]publication, 0, len(p.publishQueue))
+	for idx, pub := range p.publishQueue {
+		if !skip[idx] {
+			queue = append(queue, pub)
+		}
+	}
+	p.publishQueue = queue
+}
+
+func (p *Progress) drop(sub *subscription) {
+	atomic.AddInt64(&sub.dropped, 1)
+	atomic.AddInt64(&p.droppedEvents, 1)
+}
+
+// flushPending sends the pending terminal events of a subscriber, in order, as soon as it has room for
+// them, or gives them up if the subscriber is removed; then it closes the chan if requested meanwhile.
+func (p *Progress) flushPending(sub *subscription) {
+	for {
+		sub.pendingMutex.Lock()
+		if len(sub.pending) == 0 {
+			sub.flushing = false
+			closing := sub.closing
+			sub.pendingMutex.Unlock()
+			if closing {
+				p.closeSubscription(sub)
+			}
+			return
+		}
+		step := sub.pending[0] // kept in the queue while sent, so the dispatcher queues the next ones after it
+		sub.pendingMutex.Unlock()
+
+		select {
+		case sub.ch <- step:
+			sub.pendingMutex.Lock()
+			sub.pending = sub.pending[1:]
+			sub.pendingMutex.Unlock()
+		case <-sub.detached:
+			sub.pendingMutex.Lock()
+			sub.pending = nil
+			sub.pendingMutex.Unlock()
+		}
+	}
+}
+
+// closeSubscription closes the chan of a removed subscriber, once its pending events are sent, then
+// forgets it, see closingSubscribers.
+func (p *Progress) closeSubscription(sub *subscription) {
+	sub.pendingMutex.Lock()
+	if sub.flushing {
+		sub.closing = true // closed by flushPending
+		sub.pendingMutex.Unlock()
+		return
+	}
+	close(sub.ch)
+	sub.pendingMutex.Unlock()
+
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	for idx, existing := range p.closingSubscribers {
+		if existing == sub {
+			p.closingSubscribers = append(p.closingSubscribers[:idx:idx], p.closingSubscribers[idx+1:]...)
+			break
+		}
+	}
+}
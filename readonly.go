@@ -0,0 +1,95 @@
+package progress
+
+import "time"
+
+// ReadOnlyProgress is a view of a Progress that can observe it but not alter it, see Progress.Freeze.
+type ReadOnlyProgress interface {
+	Snapshot() Snapshot
+	Progress() float64
+	Len() int
+	// Get returns a view of the step with the provided 'id', or nil if there is none.
+	Get(id string) ReadOnlyStep
+	// Subscribe is equivalent to Progress.Subscribe; the received steps are copies, their changes are
+	// not applied to the progress.
+	Subscribe() <-chan *Step
+	Unsubscribe(subscriber <-chan *Step)
+}
+
+// ReadOnlyStep is a view of a Step that can observe it but not alter it, see Progress.Freeze.
+type ReadOnlyStep interface {
+	ID() string
+	Title() string
+	State() State
+	Progress() float64
+	Duration() time.Duration
+}
+
+// Freeze returns a read-only view of the progress, i.e., for a rendering layer that should not alter the
+// pipeline. The view is live: it reflects the subsequent changes of the progress.
+func (p *Progress) Freeze() ReadOnlyProgress {
+	return frozenProgress{p: p}
+}
+
+type frozenProgress struct {
+	p *Progress
+}
+
+func (f frozenProgress) Snapshot() Snapshot {
+	return f.p.Snapshot()
+}
+
+func (f frozenProgress) Progress() float64 {
+	return f.p.Progress()
+}
+
+func (f frozenProgress) Len() int {
+	return f.p.Len()
+}
+
+func (f frozenProgress) Get(id string) ReadOnlyStep {
+	step, err := f.p.SafeGet(id)
+	if err != nil {
+		return nil
+	}
+	return frozenStep{s: step}
+}
+
+func (f frozenProgress) Subscribe() <-chan *Step {
+	return f.p.Subscribe()
+}
+
+func (f frozenProgress) Unsubscribe(subscriber <-chan *Step) {
+	f.p.Unsubscribe(subscriber)
+}
+
+type frozenStep struct {
+	s *Step
+}
+
+func (f frozenStep) ID() string {
+	return f.s.ID
+}
+
+func (f frozenStep) Title() string {
+	f.s.parent.mainMutex.RLock()
+	defer f.s.parent.mainMutex.RUnlock()
+	return f.s.title()
+}
+
+func (f frozenStep) State() State {
+	f.s.parent.mainMutex.RLock()
+	defer f.s.parent.mainMutex.RUnlock()
+	return f.s.State
+}
+
+func (f frozenStep) Progress() float64 {
+	f.s.parent.mainMutex.RLock()
+	defer f.s.parent.mainMutex.RUnlock()
+	return f.s.currentProgress()
+}
+
+func (f frozenStep) Duration() time.Duration {
+	f.s.parent.mainMutex.RLock()
+	defer f.s.parent.mainMutex.RUnlock()
+	return f.s.Duration()
+}
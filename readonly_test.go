@@ -0,0 +1,60 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestFreeze(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").SetName("first")
+	prog.AddStep("step2")
+	view := prog.Freeze()
+	require.Equal(t, 2, view.Len())
+	require.Nil(t, view.Get("unknown"))
+	require.Nil(t, view.Get(""))
+
+	ch := view.Subscribe()
+	defer view.Unsubscribe(ch)
+
+	// the view is live
+	prog.Get("step1").SetProgress(0.4)
+	step := view.Get("step1")
+	require.Equal(t, "step1", step.ID())
+	require.Equal(t, "first", step.Title())
+	require.Equal(t, progress.StateInProgress, step.State())
+	require.Equal(t, 0.4, step.Progress())
+	require.True(t, step.Duration() >= 0)
+	require.Equal(t, 0.2, view.Progress())
+	require.Equal(t, progress.StateInProgress, view.Snapshot().State)
+	require.Equal(t, "step1", (<-ch).ID)
+}
+
+func TestFreeze_subscribeCopies(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	view := prog.Freeze()
+	ch := view.Subscribe()
+	step := prog.AddStep("step1")
+
+	received := <-ch
+	received.Start().SetDescription("altered").Done()
+	require.False(t, received.Attached())
+	require.Equal(t, progress.StateNotStarted, step.State)
+	require.Empty(t, step.Description)
+	select {
+	case event := <-ch:
+		t.Fatalf("unexpected event: %v", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// the copies returned by Since and Capture too
+	since, _ := prog.Since(time.Time{})
+	since[0].Done()
+	captured := prog.Capture()
+	captured[0].Done()
+	require.Equal(t, progress.StateNotStarted, step.State)
+}
@@ -0,0 +1,262 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Table columns supported by RenderTable.
+const (
+	ColumnID          = "ID"
+	ColumnName        = "Name"
+	ColumnDescription = "Description"
+	ColumnState       = "State"
+	ColumnProgress    = "Progress"
+	ColumnDuration    = "Duration"
+)
+
+const (
+	defaultTableMaxCellWidth = 40
+	groupBarWidth            = 20
+	renderLoopBarWidth       = 30
+	defaultRenderInterval    = 100 * time.Millisecond
+	ansiClearLine            = "\033[K"
+	ansiReset                = "\033[0m"
+)
+
+var (
+	defaultTableColumns = []string{ColumnID, ColumnState, ColumnProgress, ColumnDuration}
+	stateColors         = map[State]string{
+		StateNotStarted: "\033[90m", // gray
+		StatePending:    "\033[36m", // cyan
+		StateInProgress: "\033[33m", // yellow
+		StateDone:       "\033[32m", // green
+		StateStopped:    "\033[31m", // red
+		StateFailed:     "\033[31m", // red
+	}
+)
+
+// TableOptions configures RenderTable.
+type TableOptions struct {
+	// Color enables ANSI colors for the State column.
+	Color bool
+	// Columns is the ordered list of columns to render, the default is ID, State, Progress and Duration.
+	Columns []string
+	// MaxCellWidth truncates the cells that are longer than this width, the default is 40.
+	MaxCellWidth int
+	// Groups renders the steps of each group (see Progress.AddGroup) in their own section, under a header
+	// with the group progress bar. The steps without a group are rendered first.
+	Groups bool
+}
+
+// RenderTable returns an aligned, human-readable table of the steps.
+func (p *Progress) RenderTable(opts TableOptions) string {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = defaultTableColumns
+	}
+	maxWidth := opts.MaxCellWidth
+	if maxWidth <= 0 {
+		maxWidth = defaultTableMaxCellWidth
+	}
+
+	// compute the cells under the lock, then render them
+	rows := [][]string{columns}
+	states := []State{""}
+	headers := map[int]string{} // group headers, by index of the row they precede
+	appendRow := func(step *Step) {
+		row := make([]string, len(columns))
+		for idx, column := range columns {
+			row[idx] = truncate(step.tableCell(column), maxWidth)
+		}
+		rows = append(rows, row)
+		states = append(states, step.State)
+	}
+	p.mainMutex.RLock()
+	steps := p.sortedSteps() // see WithStateSortOrder
+	if opts.Groups {
+		for _, step := range steps {
+			if step.Group == "" {
+				appendRow(step)
+			}
+		}
+		for _, name := range p.groups {
+			snapshot := p.groupSnapshot(name)
+			headers[len(rows)] = fmt.Sprintf("%s  %s %d%%", name, renderBar(snapshot.Progress, groupBarWidth), p.percent(snapshot.Progress))
+			for _, step := range steps {
+				if step.Group == name {
+					appendRow(step)
+				}
+			}
+		}
+	} else {
+		for _, step := range steps {
+			appendRow(step)
+		}
+	}
+	p.mainMutex.RUnlock()
+
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for idx, cell := range row {
+			if width := utf8.RuneCountInString(cell); width > widths[idx] {
+				widths[idx] = width
+			}
+		}
+	}
+
+	var b strings.Builder
+	for rowIdx, row := range rows {
+		if header, found := headers[rowIdx]; found {
+			b.WriteString(header + "\n")
+		}
+		for idx, cell := range row {
+			if idx > 0 {
+				b.WriteString("  ")
+			}
+			padded := cell
+			if idx < len(row)-1 {
+				padded += strings.Repeat(" ", widths[idx]-utf8.RuneCountInString(cell))
+			}
+			if color, found := stateColors[states[rowIdx]]; opts.Color && found && columns[idx] == ColumnState {
+				padded = color + padded + ansiReset
+			}
+			b.WriteString(padded)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// tableCell returns the value of a table column, it should be called while holding the lock.
+func (s *Step) tableCell(column string) string {
+	switch column {
+	case ColumnID:
+		return s.ID
+	case ColumnName:
+		return s.Name
+	case ColumnDescription:
+		return s.Description
+	case ColumnState:
+		return string(s.State)
+	case ColumnProgress:
+		return strconv.Itoa(s.percent()) + "%"
+	case ColumnDuration:
+		if duration := s.Duration(); duration > 0 {
+			return duration.Round(time.Millisecond).String()
+		}
+		return "-"
+	}
+	return ""
+}
+
+// RenderBar returns a single-line summary of the progress: a bar of 'width' characters, the percentage,
+// the number of completed steps and the in-progress steps, i.e., "[######----] 60% 3/5 build, test".
+// A negative 'width' is handled as zero, i.e., "[] 60% 3/5 build, test".
+// The line begins with the prefix, if any (see WithPrefix), and is truncated to the render width (see
+// WithRenderWidth), i.e., "deploy-prod [######----] 60% 3/5 build, test".
+func (p *Progress) RenderBar(width int) string {
+	p.mainMutex.RLock()
+	snapshot := p.snapshot()
+	prefix := p.renderPrefix
+	if prefix == "" {
+		prefix = p.Name
+	}
+	maxWidth := p.renderWidth
+	p.mainMutex.RUnlock()
+
+	line := fmt.Sprintf("%s %d%% %d/%d", renderBar(snapshot.Progress, width), p.percent(snapshot.Progress), snapshot.Completed, snapshot.Total)
+	if prefix != "" {
+		line = prefix + " " + line
+	}
+	if snapshot.Doing != "" {
+		line += " " + snapshot.Doing
+	}
+	if maxWidth > 0 {
+		line = truncate(line, maxWidth)
+	}
+	return line
+}
+
+// RenderLoop repaints RenderBar in place on 'w' (i.e., a terminal), at most once per 'interval' and only
+// when the progress changed, until the progress is complete or the context is canceled.
+// The last line is always repainted with the final state, then followed by a newline.
+// If 'interval' is zero or negative, 100ms is used.
+// It returns nil when the progress is complete, or an error when the context is canceled or a write fails.
+func (p *Progress) RenderLoop(ctx context.Context, w io.Writer, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultRenderInterval
+	}
+	ch := p.Subscribe()
+	defer p.Unsubscribe(ch)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	paint := func(suffix string) error {
+		if _, err := io.WriteString(w, "\r"+p.RenderBar(renderLoopBarWidth)+ansiClearLine+suffix); err != nil {
+			return err
+		}
+		return flush(w)
+	}
+	// the progress may already be complete, in this case no event will be published anymore
+	p.mainMutex.RLock()
+	complete := p.isComplete()
+	p.mainMutex.RUnlock()
+	if complete {
+		return paint("\n")
+	}
+	if err := paint(""); err != nil {
+		return err
+	}
+	dirty := false
+	for {
+		select {
+		case <-ctx.Done():
+			if err := paint("\n"); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case step, ok := <-ch:
+			if !ok || step == nil || step.IsCompletion() {
+				return paint("\n")
+			}
+			dirty = true
+		case <-ticker.C:
+			if !dirty {
+				continue
+			}
+			dirty = false
+			if err := paint(""); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// renderBar returns a progress bar of 'width' characters.
+func renderBar(progress float64, width int) string {
+	if width < 0 {
+		width = 0
+	}
+	filled := int(progress * float64(width))
+	switch {
+	case filled > width:
+		filled = width
+	case filled < 0:
+		filled = 0
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+func truncate(input string, width int) string {
+	if utf8.RuneCountInString(input) <= width {
+		return input
+	}
+	runes := []rune(input)
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,163 @@
+package progress_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestRenderTable(t *testing.T) {
+	// empty progress
+	require.Equal(t, "ID  State  Progress  Duration\n", progress.New().RenderTable(progress.TableOptions{}))
+
+	prog := progress.New()
+	prog.AddStep("init").Done()
+	prog.AddStep("step1").SetProgress(0.42)
+	prog.AddStep("a-very-long-step-id")
+
+	out := prog.RenderTable(progress.TableOptions{Columns: []string{progress.ColumnID, progress.ColumnState, progress.ColumnProgress}})
+	require.Equal(t, ""+
+		"ID                   State        Progress\n"+
+		"init                 done         100%\n"+
+		"step1                in progress  42%\n"+
+		"a-very-long-step-id  not started  0%\n", out)
+
+	// truncate long cells
+	prog.Get("step1").SetDescription("this is a very long description that should be truncated")
+	out = prog.RenderTable(progress.TableOptions{Columns: []string{progress.ColumnDescription}, MaxCellWidth: 10})
+	require.Equal(t, "Description\n\nthis is a…\n\n", out)
+
+	// colors
+	out = prog.RenderTable(progress.TableOptions{Color: true})
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 5)
+	require.NotContains(t, lines[0], "\033[")
+	require.Contains(t, lines[1], "\033[32mdone       \033[0m")
+	require.Contains(t, lines[2], "\033[33min progress\033[0m")
+	require.Contains(t, lines[3], "\033[90mnot started\033[0m")
+}
+
+func TestRenderTable_groups(t *testing.T) {
+	prog := progress.New()
+	build := prog.AddGroup("build")
+	test := prog.AddGroup("test")
+	test.AddStep("unit")
+	build.AddStep("compile").Done()
+	build.AddStep("link")
+	prog.AddStep("notify")
+
+	out := prog.RenderTable(progress.TableOptions{Columns: []string{progress.ColumnID, progress.ColumnState}, Groups: true})
+	require.Equal(t, ""+
+		"ID       State\n"+
+		"notify   not started\n"+
+		"build  [##########----------] 50%\n"+
+		"compile  done\n"+
+		"link     not started\n"+
+		"test  [--------------------] 0%\n"+
+		"unit     not started\n", out)
+}
+
+func TestRenderBar(t *testing.T) {
+	prog := progress.New()
+	require.Equal(t, "[----------] 0% 0/0", prog.RenderBar(10))
+	prog.AddStep("build").Done()
+	prog.AddStep("test").SetDescription("testing").Start()
+	prog.AddStep("deploy")
+	require.Equal(t, "[#####-----] 50% 1/3 testing", prog.RenderBar(10))
+	require.Equal(t, "[] 50% 1/3 testing", prog.RenderBar(0))
+	require.Equal(t, "[] 50% 1/3 testing", prog.RenderBar(-1))
+}
+
+func TestRenderBar_prefix(t *testing.T) {
+	prog := progress.New(progress.WithName("deploy-prod"))
+	prog.AddStep("build").Done()
+	prog.AddStep("test")
+	require.Equal(t, "deploy-prod [####----] 50% 1/2", prog.RenderBar(8))
+
+	prog = progress.New(progress.WithName("deploy-prod"), progress.WithPrefix("prod"), progress.WithRenderWidth(20))
+	prog.AddStep("build").Done()
+	prog.AddStep("test").SetDescription("running the integration tests").Start()
+	require.Equal(t, "prod [######--] 75%…", prog.RenderBar(8))
+}
+
+func TestRenderLoop(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- prog.RenderLoop(context.Background(), &buf, 5*time.Millisecond)
+	}()
+	time.Sleep(10 * time.Millisecond) // wait for the subscription
+
+	step := prog.AddStep("step1").Start()
+	time.Sleep(20 * time.Millisecond)
+	step.Done()
+	require.NoError(t, <-done)
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "\r"))
+	require.True(t, strings.HasSuffix(out, "\r"+prog.RenderBar(30)+"\033[K\n"))
+	require.Contains(t, out, "50% 0/1 step1\033[K")
+	require.Equal(t, 1, strings.Count(out, "\n"))
+
+	// canceled
+	prog = progress.New()
+	prog.AddStep("step1").Start()
+	buf.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, prog.RenderLoop(ctx, &buf, 0))
+	require.True(t, strings.HasSuffix(buf.String(), "\033[K\n"))
+
+	// already complete
+	prog = progress.New()
+	prog.AddStep("step1").Done()
+	buf.Reset()
+	go func() {
+		done <- prog.RenderLoop(context.Background(), &buf, 0)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("RenderLoop should return when the progress is already complete")
+	}
+	require.Equal(t, "\r"+prog.RenderBar(30)+"\033[K\n", buf.String())
+}
+
+func TestRenderTable_stateSortOrder(t *testing.T) {
+	prog := progress.New(progress.WithStateSortOrder([]progress.State{progress.StateInProgress, progress.StateNotStarted, progress.StateDone}))
+	prog.AddStep("init").Done()
+	prog.AddStep("step1").Fail(nil)
+	prog.AddStep("step2")
+	prog.AddStep("step3").Start()
+	prog.AddStep("step4")
+
+	out := prog.RenderTable(progress.TableOptions{Columns: []string{progress.ColumnID, progress.ColumnState}})
+	require.Equal(t, ""+
+		"ID     State\n"+
+		"step3  in progress\n"+
+		"step2  not started\n"+
+		"step4  not started\n"+
+		"init   done\n"+
+		"step1  failed\n", out)
+
+	ids := []string{}
+	for _, step := range prog.SortedSteps() {
+		ids = append(ids, step.ID)
+	}
+	require.Equal(t, []string{"step3", "step2", "step4", "init", "step1"}, ids)
+	require.Equal(t, "init", prog.Steps[0].ID) // untouched
+
+	ids = []string{}
+	for _, step := range progress.New(progress.WithSteps("b", "a")).SortedSteps() {
+		ids = append(ids, step.ID)
+	}
+	require.Equal(t, []string{"b", "a"}, ids)
+}
@@ -0,0 +1,128 @@
+package progress
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SnapshotJSONSchema returns a JSON Schema (draft 2020-12) document describing the JSON encoding of a
+// Snapshot, i.e., to publish the response schema of an HTTP API.
+// The schema is generated from the struct fields and their JSON tags, so it follows the new fields.
+// The durations are integers in nanoseconds.
+func SnapshotJSONSchema() []byte {
+	return jsonSchema(reflect.TypeOf(Snapshot{}))
+}
+
+// ProgressJSONSchema is equivalent to SnapshotJSONSchema, but it describes the JSON encoding of a Progress
+// (see Progress.MarshalJSON), including its steps and its snapshot.
+func ProgressJSONSchema() []byte {
+	return jsonSchema(reflect.TypeOf(Progress{}))
+}
+
+func jsonSchema(root reflect.Type) []byte {
+	g := schemaGenerator{defs: map[string]map[string]interface{}{}}
+	schema := g.schema(root)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$defs"] = g.defs
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err) // only built from marshallable values
+	}
+	return out
+}
+
+// schemaGenerator builds the schema of the types of the package, each struct is described once in defs and
+// referenced elsewhere, so the recursive types (i.e., Step.Child) are supported.
+type schemaGenerator struct {
+	defs map[string]map[string]interface{}
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	stateType    = reflect.TypeOf(State(""))
+)
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case durationType:
+		return map[string]interface{}{"type": "integer", "description": "duration in nanoseconds"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case stateType:
+		enum := make([]string, 0, len(states))
+		for _, state := range states {
+			enum = append(enum, string(state))
+		}
+		return map[string]interface{}{"type": "string", "enum": enum}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		g.define(t)
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default: // interface{}, i.e., Step.Data
+		return map[string]interface{}{}
+	}
+}
+
+// define adds the schema of the struct 't' to defs, if not already done.
+func (g *schemaGenerator) define(t reflect.Type) {
+	if _, found := g.defs[t.Name()]; found {
+		return
+	}
+	properties := map[string]interface{}{}
+	required := []string{}
+	def := map[string]interface{}{"type": "object", "properties": properties}
+	g.defs[t.Name()] = def // before the fields, for the recursive types
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if idx := strings.Index(tag, ","); idx != -1 {
+			name, options = tag[:idx], tag[idx:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+		if !strings.Contains(options, ",omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	// the fields added by the custom marshalers
+	switch t {
+	case reflect.TypeOf(Step{}):
+		properties["duration"] = g.schema(durationType) // see Step.MarshalJSON
+		properties["percent"] = map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100}
+		required = append(required, "percent")
+	case reflect.TypeOf(Progress{}):
+		properties["snapshot"] = g.schema(reflect.TypeOf(Snapshot{})) // see Progress.MarshalJSON
+		required = append(required, "snapshot")
+	}
+	if len(required) > 0 {
+		def["required"] = required
+	}
+}
@@ -0,0 +1,70 @@
+package progress_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+type jsonSchemaDoc struct {
+	Ref  string                   `json:"$ref"`
+	Defs map[string]jsonSchemaDef `json:"$defs"`
+}
+
+type jsonSchemaDef struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+func TestSnapshotJSONSchema(t *testing.T) {
+	var schema jsonSchemaDoc
+	require.NoError(t, json.Unmarshal(progress.SnapshotJSONSchema(), &schema))
+	require.Equal(t, "#/$defs/Snapshot", schema.Ref)
+	require.Contains(t, schema.Defs, "DoingEntry")
+	require.Contains(t, schema.Defs, "PhaseStats")
+	require.NotContains(t, schema.Defs, "Step")
+
+	snapshot := schema.Defs["Snapshot"]
+	require.Equal(t, []string{"total", "progress"}, snapshot.Required)
+	require.JSONEq(t, `{"type": "number"}`, string(snapshot.Properties["progress"]))
+	require.JSONEq(t, `{"type": "string", "enum": ["not started", "pending", "in progress", "done", "stopped", "failed"]}`, string(snapshot.Properties["state"]))
+	require.JSONEq(t, `{"type": "integer", "description": "duration in nanoseconds"}`, string(snapshot.Properties["total_duration"]))
+	require.JSONEq(t, `{"type": "string", "format": "date-time"}`, string(snapshot.Properties["done_at"]))
+	require.JSONEq(t, `{"type": "object", "additionalProperties": {"$ref": "#/$defs/PhaseStats"}}`, string(snapshot.Properties["phases"]))
+}
+
+func TestProgressJSONSchema(t *testing.T) {
+	var schema jsonSchemaDoc
+	require.NoError(t, json.Unmarshal(progress.ProgressJSONSchema(), &schema))
+	require.Equal(t, "#/$defs/Progress", schema.Ref)
+	require.JSONEq(t, `{"$ref": "#/$defs/Progress"}`, string(schema.Defs["Step"].Properties["child"]))
+
+	// the schema declares every field of the actual JSON encoding
+	prog := progress.New(progress.WithPhaseLabel("phase"))
+	prog.Metadata = map[string]string{"key": "value"}
+	step := prog.AddStep("step1").SetDescription("description").SetData(42).SetLabel("phase", "build")
+	step.AddWarning("warning").Log("message").SetMaxAttempts(2)
+	step.Start()
+	child := progress.New()
+	child.AddStep("child1")
+	step.SetChild(child)
+	prog.AddStep("step2").Start().Done()
+
+	var encoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(prog.JSON()), &encoded))
+	requireDeclared := func(def string, value map[string]interface{}) {
+		for key := range value {
+			require.Contains(t, schema.Defs[def].Properties, key, "%s.%s", def, key)
+		}
+		for _, key := range schema.Defs[def].Required {
+			require.Contains(t, value, key, "%s.%s", def, key)
+		}
+	}
+	requireDeclared("Progress", encoded)
+	requireDeclared("Snapshot", encoded["snapshot"].(map[string]interface{}))
+	for _, step := range encoded["steps"].([]interface{}) {
+		requireDeclared("Step", step.(map[string]interface{}))
+	}
+}
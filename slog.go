@@ -0,0 +1,86 @@
+//go:build go1.21
+
+package progress
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogWith subscribes to the progress and logs each step transition with 'logger', until the progress is
+// complete or closed: added, pending and removed steps at the debug level, started and done steps at the
+// info level, stopped steps at the warn level and failed steps at the error level.
+// Nothing is logged if the progress is already complete.
+// The logging is done in its own goroutine, so a slow logger never blocks the progress.
+func (p *Progress) LogWith(logger *slog.Logger) {
+	ch := p.Subscribe()
+	// the progress may already be complete, in this case no event will be published anymore
+	p.mainMutex.RLock()
+	complete := p.isComplete()
+	p.mainMutex.RUnlock()
+	if complete {
+		p.Unsubscribe(ch)
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		states := make(map[string]State)
+		for step := range ch {
+			switch {
+			case step == nil: // run complete, with WithPersistentSubscribers
+				continue
+			case step.IsCompletion():
+				logger.LogAttrs(ctx, slog.LevelInfo, "progress complete",
+					slog.String("state", string(step.Snapshot.State)),
+					slog.Int("completed", step.Snapshot.Completed),
+					slog.Int("total", step.Snapshot.Total),
+					slog.Duration("duration", step.Snapshot.TotalDuration),
+				)
+				continue
+			case step.Removed:
+				delete(states, step.ID)
+				logger.LogAttrs(ctx, slog.LevelDebug, "step removed", slog.String("id", step.ID))
+				continue
+			}
+
+			previous, known := states[step.ID]
+			states[step.ID] = step.State
+			if known && previous == step.State {
+				continue // not a transition
+			}
+			level, msg := slog.LevelDebug, "step added"
+			if known {
+				level, msg = stepLogLevel(step.State)
+			}
+			attrs := []slog.Attr{
+				slog.String("id", step.ID),
+				slog.String("state", string(step.State)),
+				slog.Float64("progress", step.Progress),
+			}
+			if duration := step.Duration(); duration > 0 {
+				attrs = append(attrs, slog.Duration("duration", duration))
+			}
+			if step.Error != "" {
+				attrs = append(attrs, slog.String("error", step.Error))
+			}
+			logger.LogAttrs(ctx, level, msg, attrs...)
+		}
+	}()
+}
+
+func stepLogLevel(state State) (slog.Level, string) {
+	switch state {
+	case StatePending:
+		return slog.LevelDebug, "step pending"
+	case StateInProgress:
+		return slog.LevelInfo, "step started"
+	case StateDone:
+		return slog.LevelInfo, "step done"
+	case StateStopped:
+		return slog.LevelWarn, "step stopped"
+	case StateFailed:
+		return slog.LevelError, "step failed"
+	default:
+		return slog.LevelDebug, "step " + state.Code()
+	}
+}
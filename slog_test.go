@@ -0,0 +1,81 @@
+//go:build go1.21
+
+package progress_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestLogWith(t *testing.T) {
+	var buf syncBuffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey || attr.Key == "duration" {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+
+	prog := progress.New()
+	prog.LogWith(logger)
+	prog.AddStep("build")
+	prog.AddStep("test")
+	prog.AddStep("lint")
+	require.NoError(t, prog.RemoveStep("lint"))
+	prog.Get("build").Start().SetProgress(0.7) // not a transition
+	prog.Get("build").Done()
+	prog.Get("test").Start().Fail(errors.New("boom"))
+
+	require.Eventually(t, func() bool { return strings.Contains(buf.String(), "progress complete") }, time.Second, time.Millisecond)
+	require.Equal(t, ""+
+		`level=DEBUG msg="step added" id=build state="not started" progress=0`+"\n"+
+		`level=DEBUG msg="step added" id=test state="not started" progress=0`+"\n"+
+		`level=DEBUG msg="step added" id=lint state="not started" progress=0`+"\n"+
+		`level=DEBUG msg="step removed" id=lint`+"\n"+
+		`level=INFO msg="step started" id=build state="in progress" progress=0.5`+"\n"+
+		`level=INFO msg="step done" id=build state=done progress=0.7`+"\n"+
+		`level=INFO msg="step started" id=test state="in progress" progress=0.5`+"\n"+
+		`level=ERROR msg="step failed" id=test state=failed progress=0.5 error=boom`+"\n"+
+		`level=INFO msg="progress complete" state=failed completed=1 total=2`+"\n", buf.String())
+}
+
+func TestLogWith_alreadyComplete(t *testing.T) {
+	var buf syncBuffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	prog := progress.New()
+	prog.AddStep("step1").Done()
+
+	before := runtime.NumGoroutine()
+	prog.LogWith(logger)
+	requireNoGoroutineLeak(t, before)
+	require.Empty(t, buf.String())
+}
@@ -0,0 +1,111 @@
+package progress
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SubscribeSnapshots returns a chan receiving the recomputed snapshot of the progress after each change,
+// for the subscribers that only need the overall stats instead of the changed steps (see Subscribe).
+// With WithPublishInterval, the rapid changes are coalesced, so at most one snapshot is sent per interval,
+// the latest one being flushed at the end of the interval.
+// The chan is closed like the step subscribers, after the final snapshot, when the progress is complete
+// (unless WithPersistentSubscribers is used), on Close, or on UnsubscribeSnapshots.
+// A snapshot is dropped for a subscriber that is too slow to receive it, see DroppedEvents.
+func (p *Progress) SubscribeSnapshots() <-chan Snapshot {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	subscriber := make(chan Snapshot, defaultSubscriberChanLength)
+	p.snapshotSubscribers = append(p.snapshotSubscribers, subscriber)
+	return subscriber
+}
+
+// UnsubscribeSnapshots unregisters and closes a chan previously returned by SubscribeSnapshots.
+// It is safe to call it on an already closed subscriber.
+func (p *Progress) UnsubscribeSnapshots(subscriber <-chan Snapshot) {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	for idx, ch := range p.snapshotSubscribers {
+		if ch == subscriber {
+			p.snapshotSubscribers = append(p.snapshotSubscribers[:idx:idx], p.snapshotSubscribers[idx+1:]...)
+			// closed by the dispatcher, after the snapshots that are already queued for it
+			p.enqueue(publication{closeSnapshots: []chan Snapshot{ch}})
+			return
+		}
+	}
+}
+
+// publishSnapshot queues the current snapshot for the snapshot subscribers, respecting the publish
+// interval, it should be called while holding the lock. It does nothing while deferSnapshots is set.
+func (p *Progress) publishSnapshot() {
+	if len(p.snapshotSubscribers) == 0 || p.deferSnapshots {
+		return
+	}
+	if p.publishInterval <= 0 || time.Since(p.lastSnapshotPublish) >= p.publishInterval {
+		p.flushSnapshot()
+		return
+	}
+	if p.snapshotTimer != nil { // a flush is already scheduled
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(p.publishInterval-time.Since(p.lastSnapshotPublish), func() {
+		p.mainMutex.Lock()
+		defer p.mainMutex.Unlock()
+		if p.snapshotTimer != timer { // already flushed
+			return
+		}
+		p.flushSnapshot()
+	})
+	p.snapshotTimer = timer
+}
+
+// flushSnapshot immediately queues the current snapshot, superseding a pending coalesced one, it should be
+// called while holding the lock.
+func (p *Progress) flushSnapshot() {
+	if p.snapshotTimer != nil {
+		p.snapshotTimer.Stop()
+		p.snapshotTimer = nil
+	}
+	if len(p.snapshotSubscribers) == 0 {
+		return
+	}
+	p.lastSnapshotPublish = time.Now()
+	snapshot := p.snapshot()
+	targets := append([]chan Snapshot{}, p.snapshotSubscribers...)
+	p.enqueue(publication{snapshot: &snapshot, snapshotTargets: targets})
+}
+
+// closeSnapshotSubscribers flushes the pending snapshot, if any, then closes the snapshot subscribers, it
+// should be called while holding the lock.
+func (p *Progress) closeSnapshotSubscribers() {
+	if p.snapshotTimer != nil {
+		p.flushSnapshot()
+	}
+	if len(p.snapshotSubscribers) == 0 {
+		return
+	}
+	subs := p.snapshotSubscribers
+	p.snapshotSubscribers = nil
+	p.enqueue(publication{closeSnapshots: subs})
+}
+
+// deliverSnapshot sends the snapshot of a publication to its targets, like deliver for the steps, it
+// should only be called by the dispatcher.
+func (p *Progress) deliverSnapshot(pub publication) {
+	slow := make([]chan Snapshot, 0, len(pub.snapshotTargets))
+	for _, ch := range pub.snapshotTargets {
+		select {
+		case ch <- *pub.snapshot:
+		default:
+			slow = append(slow, ch)
+		}
+	}
+	for _, ch := range slow {
+		select {
+		case ch <- *pub.snapshot:
+		case <-time.After(publishTimeout):
+			atomic.AddInt64(&p.droppedEvents, 1)
+		}
+	}
+}
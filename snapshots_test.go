@@ -0,0 +1,82 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestProgress_SubscribeSnapshots(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ch := prog.SubscribeSnapshots()
+
+	prog.AddStep("step1")
+	prog.AddStep("step2")
+	prog.Get("step1").Start()
+	prog.Get("step1").Done()
+	prog.Get("step2").Start()
+	prog.Get("step2").Done()
+
+	snapshots := []progress.Snapshot{}
+	for snapshot := range ch {
+		snapshots = append(snapshots, snapshot)
+	}
+	require.Len(t, snapshots, 7) // 6 changes and the completion
+	require.Equal(t, 1, snapshots[0].Total)
+	require.Equal(t, progress.StateNotStarted, snapshots[1].State)
+	require.Equal(t, 2, snapshots[1].Total)
+	require.Equal(t, progress.StateInProgress, snapshots[2].State)
+	require.Equal(t, "step1", snapshots[2].Doing)
+	require.Equal(t, 1, snapshots[3].Completed)
+	last := snapshots[len(snapshots)-1]
+	require.Equal(t, progress.StateDone, last.State)
+	require.Equal(t, 2, last.Completed)
+	require.Equal(t, float64(1), last.Progress)
+}
+
+func TestProgress_SubscribeSnapshots_coalesced(t *testing.T) {
+	prog := progress.New(progress.WithPublishInterval(50 * time.Millisecond))
+	defer prog.Close()
+	ch := prog.SubscribeSnapshots()
+
+	step := prog.AddStep("step1").Start()
+	for i := 1; i <= 100; i++ {
+		step.SetProgress(float64(i) / 200)
+	}
+	time.Sleep(100 * time.Millisecond)
+	step.Done()
+
+	snapshots := []progress.Snapshot{}
+	for snapshot := range ch {
+		snapshots = append(snapshots, snapshot)
+	}
+	require.True(t, len(snapshots) < 10)
+	// the coalesced value is flushed at the end of the interval
+	flushed := false
+	for _, snapshot := range snapshots {
+		if snapshot.Progress == 0.5 {
+			flushed = true
+		}
+	}
+	require.True(t, flushed)
+	require.Equal(t, progress.StateDone, snapshots[len(snapshots)-1].State)
+}
+
+func TestProgress_UnsubscribeSnapshots(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ch := prog.SubscribeSnapshots()
+	prog.AddStep("step1")
+	prog.UnsubscribeSnapshots(ch)
+	prog.UnsubscribeSnapshots(ch) // already closed
+
+	count := 0
+	for range ch {
+		count++
+	}
+	require.Equal(t, 1, count)
+	prog.AddStep("step2")
+}
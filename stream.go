@@ -0,0 +1,57 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// StreamJSON subscribes to the progress and writes each published step to 'w' as JSON Lines
+// (one JSON object followed by '\n' per event).
+// If 'w' implements a Flush method (i.e., *bufio.Writer or http.Flusher), it is called after each line.
+// It returns nil when the progress is done, without writing anything if it was already complete, or an
+// error when the context is canceled or a write fails.
+func (p *Progress) StreamJSON(ctx context.Context, w io.Writer) error {
+	ch := p.Subscribe()
+	defer p.Unsubscribe(ch)
+
+	// the progress may already be complete, in this case no event will be published anymore
+	p.mainMutex.RLock()
+	complete := p.isComplete()
+	p.mainMutex.RUnlock()
+	if complete {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case step, ok := <-ch:
+			if !ok || step == nil {
+				return nil
+			}
+			line, err := json.Marshal(step)
+			if err != nil {
+				return err
+			}
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+			if err := flush(w); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func flush(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package progress_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+func TestStreamJSON(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- prog.StreamJSON(context.Background(), &buf)
+	}()
+	time.Sleep(10 * time.Millisecond) // wait for the subscription
+
+	prog.AddStep("step1")
+	prog.Get("step1").Start()
+	prog.Get("step1").Done()
+	require.NoError(t, <-done)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	expected := []progress.State{progress.StateNotStarted, progress.StateInProgress, progress.StateDone}
+	for idx, line := range lines[:3] {
+		var step struct {
+			ID    string         `json:"id"`
+			State progress.State `json:"state"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &step))
+		require.Equal(t, "step1", step.ID)
+		require.Equal(t, expected[idx], step.State)
+	}
+
+	// the last line is the completion event, with the final snapshot
+	var completion progress.Step
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &completion))
+	require.True(t, completion.IsCompletion())
+	require.Equal(t, progress.StateDone, completion.Snapshot.State)
+	require.Equal(t, 1, completion.Snapshot.Completed)
+}
+
+func TestStreamJSON_alreadyComplete(t *testing.T) {
+	prog := progress.New()
+	prog.AddStep("step1").Done()
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- prog.StreamJSON(context.Background(), &buf)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("StreamJSON should return when the progress is already complete")
+	}
+	require.Empty(t, buf.String())
+}
+
+func TestStreamJSON_cancel(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- prog.StreamJSON(ctx, bufio.NewWriter(&buf))
+	}()
+	time.Sleep(10 * time.Millisecond) // wait for the subscription
+
+	prog.AddStep("step1")
+	cancel()
+	require.True(t, errors.Is(<-done, context.Canceled))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("boom") }
+
+func TestStreamJSON_writeError(t *testing.T) {
+	prog := progress.New()
+	defer prog.Close()
+	done := make(chan error)
+	go func() {
+		done <- prog.StreamJSON(context.Background(), failingWriter{})
+	}()
+	time.Sleep(10 * time.Millisecond) // wait for the subscription
+
+	prog.AddStep("step1")
+	require.EqualError(t, <-done, "boom")
+
+	// the stream has unsubscribed, so publishing doesn't block anymore
+	prog.AddStep("step2")
+}
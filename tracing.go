@@ -0,0 +1,67 @@
+package progress
+
+import (
+	"context"
+	"errors"
+)
+
+// Tracer is the minimal tracing interface used to emit a span per step, see WithTracer.
+// It can easily wrap an OpenTelemetry trace.Tracer.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a span created by a Tracer.
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+// inheritTracing configures the tracing of a child progress.
+func (p *Progress) inheritTracing(tracer Tracer, ctx context.Context) {
+	p.mainMutex.Lock()
+	defer p.mainMutex.Unlock()
+	if p.tracer == nil {
+		p.tracer = tracer
+	}
+	p.spanCtx = ctx
+}
+
+// startSpan starts the step span, it should be called while holding the lock.
+func (s *Step) startSpan() {
+	tracer := s.parent.tracer
+	if tracer == nil || s.span != nil {
+		return
+	}
+	ctx := s.parent.spanCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.spanCtx, s.span = tracer.Start(ctx, s.title())
+	if s.Child != nil {
+		s.Child.inheritTracing(tracer, s.spanCtx)
+	}
+}
+
+// endSpan ends the step span, it should be called while holding the lock.
+func (s *Step) endSpan(err error) {
+	if s.span == nil {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	s.span.End()
+	s.span = nil
+}
+
+func errStepStopped(reason string, cancelled bool) error {
+	switch {
+	case reason != "":
+		return errors.New(reason)
+	case cancelled:
+		return errors.New("step cancelled")
+	default:
+		return errors.New("step stopped")
+	}
+}
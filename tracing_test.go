@@ -0,0 +1,72 @@
+package progress_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"moul.io/progress"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	name   string
+	parent string
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) RecordError(err error) { s.err = err }
+func (s *testSpan) End()                  { s.ended = true }
+
+type testTracer struct {
+	mutex sync.Mutex
+	spans map[string]*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, progress.Span) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	span := &testSpan{name: name}
+	if parent, ok := ctx.Value(testSpanKey{}).(*testSpan); ok {
+		span.parent = parent.name
+	}
+	if t.spans == nil {
+		t.spans = make(map[string]*testSpan)
+	}
+	t.spans[name] = span
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func TestWithTracer(t *testing.T) {
+	tracer := &testTracer{}
+	prog := progress.New(progress.WithTracer(tracer))
+	prog.AddStep("build")
+	prog.AddStep("deploy")
+	prog.AddStep("notify")
+
+	prog.Get("build").Start()
+	require.Contains(t, tracer.spans, "build")
+	require.False(t, tracer.spans["build"].ended)
+	prog.Get("build").Done()
+	require.True(t, tracer.spans["build"].ended)
+	require.NoError(t, tracer.spans["build"].err)
+
+	// nested progress
+	deploy := prog.Get("deploy").Start()
+	upload := deploy.AddSubStep("upload")
+	upload.Start()
+	upload.Done()
+	require.Equal(t, "deploy", tracer.spans["upload"].parent)
+	require.True(t, tracer.spans["upload"].ended)
+	deploy.Done()
+	require.True(t, tracer.spans["deploy"].ended)
+	require.Empty(t, tracer.spans["deploy"].parent)
+
+	// stopped steps end their span with an error
+	prog.Get("notify").Start().Cancel("cancelled by user")
+	require.True(t, tracer.spans["notify"].ended)
+	require.EqualError(t, tracer.spans["notify"].err, "cancelled by user")
+}